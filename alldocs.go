@@ -9,52 +9,143 @@ Usage:
 
 The commands are:
 
-        fetch       fetch a remote dependency
-        restore     restore dependencies from manifest
-        update      update a local dependency
-        list        list dependencies one per line
-        delete      delete a local dependency
+        fetch           fetch a remote dependency
+        restore         restore dependencies from manifest
+        update          update a local dependency
+        list            list dependencies one per line
+        delete          delete a local dependency
+        pin             prevent a dependency from being updated
+        unpin           allow a pinned dependency to be updated
+        verify          check vendored files against the manifest checksums
+        check           find inconsistencies between the vendor folder and the manifest
+        export          generate go.mod and vendor/modules.txt from the manifest
+        import          import dependencies from another vendoring tool
+        diff            compare two manifests
+        merge-manifest  merge manifests, as a git merge driver
+        cache           inspect, verify and prune the repository cache
+        clean           remove temporary folders left by interrupted runs
 
 Use "gvt help [command]" for more information about a command.
 
+Repositories are downloaded through a cache of mirrors, shared by all the gvt
+processes of the user and updated incrementally. The cache is in $GVTCACHE, by
+default the gvt folder in the user cache folder ($XDG_CACHE_HOME or ~/.cache on
+Linux). Set GVTCACHE=off to disable it, and see "gvt help cache" to manage it.
+
+Every command accepts the -timeout d and -op-timeout d flags, which abort it if
+it runs for longer than the duration d, like 10m, or if any git, hg or bzr command
+it runs does. The VCS commands don't prompt for credentials: git is run with
+GIT_TERMINAL_PROMPT=0 and ssh in BatchMode, and hg with --noninteractive. If an
+ssh command is set with GIT_SSH, GIT_SSH_COMMAND or the core.sshCommand git
+setting, it is used as is, and it should not prompt either.
+
 
 Fetch a remote dependency
 
 Usage:
-        gvt fetch [-branch branch] [-revision rev | -tag tag] [-precaire] [-no-recurse] [-t|-a] importpath
+        gvt fetch [-branch branch] [-revision rev | -tag tag | -version constraint] [-repo url] [-precaire] [-no-recurse] [-connections N] [-t|-a] [-prune] [-flatten] [-ignore-canonical] [-os list] [-arch list] [-tags list] [-file list] [-n [-json]] importpath...
 
-fetch vendors an upstream import path.
+fetch vendors one or more upstream import paths.
 
-Recursive dependencies are fetched (at their master/tip/HEAD revision), unless they
-or their parent package are already present.
+Recursive dependencies are fetched, unless they or their parent package are already
+present. If a fetched dependency pins their revisions in a lock file (vendor/manifest,
+Godeps/Godeps.json, glide.lock, Gopkg.lock or vendor.conf) those revisions are used,
+otherwise they are fetched at their master/tip/HEAD revision. When more than one lock
+file pins a dependency, the first one read wins, starting with the lock file of the
+import path being fetched.
 
 If a subpackage of a dependency being fetched is already present, it will be deleted.
 
+If a fetched package declares a canonical import path with an import comment, like
+package foo // import "gopkg.in/foo.v1", and is being vendored at a different path,
+fetch refuses and suggests the canonical path instead. Recursive dependencies are
+fetched at the path they are imported by, with a warning.
+
 The import path may include a url scheme. This may be useful when fetching dependencies
 from private repositories that cannot be probed.
 
+Repositories can be redirected to mirrors with rewrite rules, read from the .gvt.conf
+file in the project folder and from the gvt/config file in the user configuration folder
+($XDG_CONFIG_HOME or ~/.config on Linux). Each line is a rule in the form
+
+    github.com/ => https://git.internal/mirror/github.com/
+
+and the import paths and repository URLs starting with the prefix are fetched from
+the replacement instead. The longest matching prefix wins, and the project rules win
+over the user ones. The upstream repositories are never contacted. The manifest records
+both the upstream repository and the mirror when the repository follows from the host,
+like for github.com, and only the mirror otherwise.
+
+When more than one import path is supplied, the repositories are downloaded
+concurrently and the manifest is written once at the end. If any of them fails,
+the manifest is left untouched. The recursive dependencies of each package are
+also downloaded concurrently, but visited one at a time in import path order, so
+the output and the manifest don't depend on which download finishes first.
+
 Flags:
 	-t
 		fetch also _test.go files and testdata.
 	-a
 		fetch all files and subfolders, ignoring ONLY .git, .hg and .bzr.
+	-prune
+		fetch only the packages imported by the project, directly or through
+		other dependencies, plus the repository license. The package at the
+		import path is always fetched. Pruning is recorded in the manifest,
+		and followed by gvt update and gvt restore.
+	-flatten
+		strip the vendor folders of the fetched dependencies, and fetch the
+		packages vendored in them to the top-level vendor folder instead, at
+		the revisions pinned by their lock files. Packages already vendored
+		at a different revision are reported as conflicts. Flattening is
+		recorded in the manifest, and followed by gvt update and gvt restore.
 	-branch branch
 		fetch from the named branch. Will also be used by gvt update.
 		If not supplied the default upstream branch will be used.
 	-no-recurse
 		do not fetch recursively.
+	-connections
+		count of parallel download connections.
 	-tag tag
 		fetch the specified tag.
+	-version constraint
+		fetch the highest tag that is a semantic version matching the constraint,
+		like "^1.4", "~1.4.2", "1.x" or ">=1.2, <1.5". The constraint is recorded
+		in the manifest and used by gvt update. Only supported for git.
 	-revision rev
 		fetch the specific revision from the branch or repository.
 		If no revision supplied, the latest available will be fetched.
+	-repo url
+		fetch the import path from the repository at url instead of the one
+		the import path resolves to. url can also be a file:// URL or an
+		absolute local path, to vendor from a local mirror. The root of the
+		repository is known from the host for github.com and the like, and
+		is otherwise detected by looking for the import path subfolders,
+		resolving the import path upstream only if that is ambiguous.
+	-ignore-canonical
+		fetch the import path even if it's not the canonical import path of
+		its packages, only warning about it.
 	-precaire
 		allow the use of insecure protocols.
+	-file list
+		fetch also the import paths listed in the file, one per line.
+		Empty lines and lines starting with # are ignored.
+	-os list, -arch list, -tags list
+		only consider the imports of files that would be built for any of
+		the comma separated GOOS and GOARCH values, with the given build tags.
+		If none is supplied all files are considered, including those with
+		an ignore build tag. If only some are, the others default to all the
+		known values and no additional tags.
+	-n
+		resolve the repositories and revisions, and print the changes to the
+		manifest without making them. Neither the vendor folder nor the
+		manifest are modified.
+	-json
+		with -n, print the changes as a JSON array, like gvt diff -json.
 
 Restore dependencies from manifest
 
 Usage:
-        gvt restore [-precaire] [-connections N]
+        gvt restore [-precaire] [-connections N] [-prune] [-flatten] [-n [-json]]
 
 restore fetches the dependencies listed in the manifest.
 
@@ -64,6 +155,17 @@ source, for example if .gitignore includes lines like
     vendor/**
     !vendor/manifest
 
+Rewrite rules are applied as described in "gvt help fetch". If a repository can't
+be reached, the mirror it was fetched from, if recorded in the manifest, is used.
+
+If a restored dependency has a manifest in its vendor folder, the dependencies
+listed there are restored in that folder too. Dependencies fetched with -flatten
+are instead restored without their vendor folder, and the dependencies listed in
+their manifest are added to the top-level one if missing.
+
+Dependencies fetched with -prune are restored with the packages recorded in the
+manifest.
+
 Note that such a setup requires "gvt restore" to build the source, relies on
 the availability of the dependencies repositories and breaks "go get".
 
@@ -72,11 +174,26 @@ Flags:
 		allow the use of insecure protocols.
 	-connections
 		count of parallel download connections.
+	-prune
+		after restoring, prune all the dependencies like gvt fetch -prune,
+		recomputing the packages imported by the project, and record it
+		in the manifest.
+	-flatten
+		restore all the dependencies like gvt fetch -flatten, as described
+		above, and record it in the manifest. Dependencies listed in nested
+		manifests at a different revision than the top-level one are reported
+		as conflicts.
+	-n
+		check that the repositories can be reached, and print the dependencies
+		that would be fetched, as added if missing from the vendor folder or
+		changed if their files were modified. Nothing is modified.
+	-json
+		with -n, print the changes as a JSON array, like gvt diff -json.
 
 Update a local dependency
 
 Usage:
-        gvt update [ -all | importpath ]
+        gvt update [-prune] [-os list] [-arch list] [-tags list] [-n [-json]] [ -all | importpath ]
 
 update replaces the source with the latest available from the head of the fetched branch.
 
@@ -84,6 +201,15 @@ Updating from one copy of a dependency to another is ONLY possible when the
 dependency was fetched by branch, without using -tag or -revision. It will be
 updated to the HEAD of that branch, switching branches is not supported.
 
+Dependencies fetched with -version are instead updated to the highest tag
+matching the recorded constraint.
+
+update warns about the imports of the new revisions that are not vendored.
+They can be added with gvt fetch. It also warns about the packages that declare
+a canonical import path, with an import comment, other than the vendored one.
+
+Pinned dependencies are skipped by -all, and can't be updated until unpinned.
+
 To update across branches, or from one tag/revision to another, you must first
 use delete to remove the dependency, then fetch [ -tag | -revision | -branch ]
 to replace it.
@@ -93,6 +219,21 @@ Flags:
 		update all dependencies in the manifest.
 	-precaire
 		allow the use of insecure protocols.
+	-prune
+		vendor only the packages imported by the project, like gvt fetch -prune.
+		Dependencies fetched with -prune are always pruned.
+	-os list, -arch list, -tags list
+		only consider the imports of files that would be built for any of
+		the comma separated GOOS and GOARCH values, with the given build tags.
+		If none is supplied all files are considered, including those with
+		an ignore build tag. If only some are, the others default to all the
+		known values and no additional tags.
+	-n
+		resolve the repositories and revisions, and print the changes to the
+		manifest without making them. Neither the vendor folder nor the
+		manifest are modified.
+	-json
+		with -n, print the changes as a JSON array, like gvt diff -json.
 
 List dependencies one per line
 
@@ -105,17 +246,256 @@ Flags:
 	-f
 		controls the template used for printing each manifest entry. If not supplied
 		the default value is "{{.Importpath}}\t{{.Repository}}{{.Path}}\t{{.Branch}}\t{{.Revision}}"
+		followed by "\tpinned: reason" for pinned dependencies.
 
 Delete a local dependency
 
 Usage:
-        gvt delete [-all] importpath
+        gvt delete [-n [-json]] [-all] importpath
 
 delete removes a dependency from the vendor directory and the manifest
 
 Flags:
 	-all
 		remove all dependencies
+	-n
+		resolve the repositories and revisions, and print the changes to the
+		manifest without making them. Neither the vendor folder nor the
+		manifest are modified.
+	-json
+		with -n, print the changes as a JSON array, like gvt diff -json.
+
+Prevent a dependency from being updated
+
+Usage:
+        gvt pin [-reason text] importpath
+
+pin marks a dependency in the manifest so that update leaves it alone.
+
+update -all skips pinned dependencies and lists them when it's done, and
+update refuses to update a pinned dependency by name. Use unpin to remove
+the mark.
+
+Flags:
+	-reason text
+		record why the dependency is pinned. It's shown by list and update.
+
+Allow a pinned dependency to be updated
+
+Usage:
+        gvt unpin importpath
+
+unpin removes the mark set by pin, so that update handles the dependency again.
+
+Check vendored files against the manifest checksums
+
+Usage:
+        gvt verify
+
+verify recomputes the checksum of each vendored dependency and compares it
+with the one recorded in the manifest by fetch, update and restore.
+
+Each dependency that does not match is reported as one of
+
+	missing     the dependency folder does not exist
+	incomplete  the dependency folder contains no files
+	modified    the vendored files were edited, added or removed
+
+The vendor folder of each dependency is not checked, as restore fills it
+from the manifest of the dependency.
+
+Dependencies without a recorded checksum, from manifests written by older
+versions of gvt, are reported as unverified but do not cause a failure.
+Running gvt restore records their checksums.
+
+verify exits with a non-zero status if any dependency fails verification.
+
+Find inconsistencies between the vendor folder and the manifest
+
+Usage:
+        gvt check [-fix] [-precaire]
+
+check compares the content of the vendor folder with the manifest.
+
+Each problem found is reported as one of
+
+	missing      the dependency folder does not exist
+	incomplete   the dependency folder contains no files
+	overlapping  the dependency is inside another vendored dependency
+	orphan       the folder or file is not part of any dependency
+	empty        the folder contains no files and no dependencies
+
+check exits with a non-zero status if any problem is found.
+
+Flags:
+	-fix
+		fetch missing and incomplete dependencies again, remove overlapping
+		entries from the manifest and delete empty folders. Orphans are only
+		reported, as they might be code put there on purpose: fetch or delete
+		them by hand.
+	-precaire
+		allow the use of insecure protocols when fetching dependencies again.
+
+Generate go.mod and vendor/modules.txt from the manifest
+
+Usage:
+        gvt export [-module path] [-go version] [-f] [-precaire]
+
+export translates the manifest into a Go module definition, to help migrating
+a project to modules.
+
+Dependencies are grouped by repository, and each repository becomes a module
+required at the version of the vendored revision: its semver tag if it has
+one, or else a pseudo-version built from its commit time and the closest
+tag, which are read from a checkout of the repository. Versions v2 and
+higher of repositories without a go.mod are marked +incompatible.
+
+It writes go.mod in the current directory, and a vendor/modules.txt listing
+the packages already present in the vendor folder, so that "go build -mod=vendor"
+keeps working with the existing vendored source.
+
+go.sum is not written: run "go mod tidy" afterwards to produce it. It is not
+needed to build with -mod=vendor, but it is when switching away from the
+vendor folder.
+
+Flags:
+	-module path
+		module path of the project. If not supplied, it is derived from
+		the location of the project in GOPATH.
+	-go version
+		version for the go directive in go.mod. Defaults to 1.14, the first
+		version to use the vendor folder automatically.
+	-f
+		overwrite an existing go.mod.
+	-precaire
+		allow the use of insecure protocols.
+
+Import dependencies from another vendoring tool
+
+Usage:
+        gvt import [-restore] [-connections N] [-precaire] [-t|-a] file
+
+import adds to the manifest the dependencies pinned by the lock file of
+another vendoring tool. The format is detected from the file name:
+
+	Godeps.json   godep
+	glide.lock    glide
+	Gopkg.lock    dep
+	vendor.conf   vndr and trash
+
+The pinned revisions and subpackages are preserved. Repositories are resolved
+from the import paths, unless the lock file records a different source.
+
+Dependencies that are already present in the manifest are skipped.
+
+Flags:
+	-restore
+		fetch the imported dependencies into the vendor folder, like restore.
+	-connections
+		count of parallel download connections, with -restore.
+	-t
+		vendor also _test.go files and testdata.
+	-a
+		vendor all files and subfolders, ignoring ONLY .git, .hg and .bzr.
+	-precaire
+		allow the use of insecure protocols.
+
+Compare two manifests
+
+Usage:
+        gvt diff [-json] [-log] [-precaire] [old [new]]
+
+diff reports the dependencies that were added, removed, moved to a new
+revision or otherwise changed between two manifests.
+
+Each of old and new is either a manifest file, or a git revision and a path
+in the form accepted by "git show", like HEAD~1:vendor/manifest. Paths
+starting with ./ are relative to the current directory. If new is not
+supplied the manifest of the current project is used. If old is not
+supplied either, the project manifest at HEAD is used. Manifest files
+supplied as arguments must exist, while the default ones that don't exist
+are read as having no dependencies.
+
+Flags:
+	-json
+		print the changes as a JSON array.
+	-log
+		list the subjects of the upstream commits between the old and the
+		new revision. This requires checking out the repository.
+	-precaire
+		allow the use of insecure protocols.
+
+Merge manifests, as a git merge driver
+
+Usage:
+        gvt merge-manifest base ours theirs
+
+merge-manifest does a three-way merge of the dependencies in the manifests
+ours and theirs, which share the common ancestor base, and writes the result
+to ours.
+
+Dependencies changed on only one side are merged automatically. If both sides
+changed the same dependency differently, or the merge would make dependencies
+overlap, the conflicts are reported and merge-manifest exits with a non-zero
+status, keeping our version of the conflicting dependencies.
+
+To use it as the git merge driver for manifests, run
+
+	git config merge.gvt.name "gvt manifest merge driver"
+	git config merge.gvt.driver "gvt merge-manifest %O %A %B"
+
+and add to .gitattributes the line
+
+	vendor/manifest merge=gvt
+
+Inspect, verify and prune the repository cache
+
+Usage:
+        gvt cache list|size|verify|prune [-older-than d] [-unused-by [manifest...]]
+
+cache manages the mirrors kept in the repository cache, described in "gvt help".
+
+The subcommands are:
+
+	list    print the VCS, size, last use and URL of each cached repository
+	size    print the number of cached repositories and their total size
+	verify  run the integrity checks of the VCS on each cached repository,
+	        "git fsck" or "hg verify", and report the ones that fail
+	prune   remove cached repositories
+
+The last use of a repository is the last time gvt checked it out. Entries
+created by older versions of gvt report the time they were last modified.
+
+Flags of prune:
+	-older-than d
+		only remove the repositories not used for the duration d, like 720h.
+	-unused-by
+		only remove the repositories that none of the manifest files listed as
+		arguments refers to. Without arguments, the manifest of the current
+		project is used.
+
+At least one of the flags must be passed to prune. If both are passed, only
+the repositories matching both conditions are removed.
+
+Remove temporary folders left by interrupted runs
+
+Usage:
+        gvt clean [-older-than d] [-n]
+
+clean removes the temporary working copies that gvt processes which crashed or
+were killed left in the system temporary folder.
+
+gvt names them after the pid of the process that created them, and clean only
+removes the ones whose process is not running anymore. The folders created by
+older versions of gvt don't record the pid, and are removed only once they were
+not modified for an hour.
+
+Flags:
+	-older-than d
+		only remove the folders that were not modified for the duration d,
+		like 24h.
+	-n
+		print the folders that would be removed, without removing them.
 
 */
 package main
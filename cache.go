@@ -0,0 +1,200 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+	"time"
+
+	"github.com/FiloSottile/gvt/gbvendor"
+)
+
+// cacheDir returns the folder of the repository cache: $GVTCACHE, or the
+// gvt folder in the user cache folder. It returns the empty string, which
+// disables the cache, if GVTCACHE is "off" or there is no user cache folder.
+func cacheDir() string {
+	switch dir := os.Getenv("GVTCACHE"); dir {
+	case "off":
+		return ""
+	case "":
+	default:
+		return dir
+	}
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "gvt")
+}
+
+var (
+	cacheOlderThan time.Duration // prune entries unused for longer than this
+	cacheUnusedBy  bool          // prune entries not referenced by the manifests
+)
+
+func addCacheFlags(fs *flag.FlagSet) {
+	fs.DurationVar(&cacheOlderThan, "older-than", 0, "prune entries not used for this long")
+	fs.BoolVar(&cacheUnusedBy, "unused-by", false, "prune entries no listed manifest refers to")
+}
+
+var cmdCache = &Command{
+	Name:      "cache",
+	UsageLine: "cache list|size|verify|prune [-older-than d] [-unused-by [manifest...]]",
+	Short:     "inspect, verify and prune the repository cache",
+	Long: `cache manages the mirrors kept in the repository cache, described in "gvt help".
+
+The subcommands are:
+
+	list    print the VCS, size, last use and URL of each cached repository
+	size    print the number of cached repositories and their total size
+	verify  run the integrity checks of the VCS on each cached repository,
+	        "git fsck" or "hg verify", and report the ones that fail
+	prune   remove cached repositories
+
+The last use of a repository is the last time gvt checked it out. Entries
+created by older versions of gvt report the time they were last modified.
+
+Flags of prune:
+	-older-than d
+		only remove the repositories not used for the duration d, like 720h.
+	-unused-by
+		only remove the repositories that none of the manifest files listed as
+		arguments refers to. Without arguments, the manifest of the current
+		project is used.
+
+At least one of the flags must be passed to prune. If both are passed, only
+the repositories matching both conditions are removed.
+
+`,
+	Run: func(args []string) error {
+		if len(args) < 1 {
+			return fmt.Errorf("cache takes a subcommand: list, size, verify or prune")
+		}
+		if vendor.CacheDir == "" {
+			return fmt.Errorf("the repository cache is disabled")
+		}
+		sub, args := args[0], args[1:]
+
+		// the flags follow the subcommand, so they weren't parsed yet
+		if err := fs.Parse(args); err != nil {
+			return err
+		}
+		args = fs.Args()
+		if sub != "prune" && (len(args) != 0 || cacheOlderThan != 0 || cacheUnusedBy) {
+			return fmt.Errorf("cache %s takes no arguments", sub)
+		}
+
+		entries, err := vendor.CacheEntries(cmdCtx)
+		if err != nil {
+			return fmt.Errorf("could not read the cache: %v", err)
+		}
+
+		switch sub {
+		case "list":
+			w := tabwriter.NewWriter(os.Stdout, 1, 2, 1, ' ', 0)
+			for _, e := range entries {
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", e.VCS, formatSize(e.Size),
+					e.LastUsed.Local().Format("2006-01-02 15:04"), e.URL)
+			}
+			return w.Flush()
+
+		case "size":
+			var total int64
+			for _, e := range entries {
+				total += e.Size
+			}
+			fmt.Printf("%d repositories, %s in %s\n", len(entries), formatSize(total), vendor.CacheDir)
+			return nil
+
+		case "verify":
+			var failed int
+			for _, e := range entries {
+				if err := e.Verify(cmdCtx); err != nil {
+					log.Printf("%s: %v", e.URL, err)
+					failed++
+				}
+			}
+			if failed > 0 {
+				return fmt.Errorf("%d repositories failed verification", failed)
+			}
+			return nil
+
+		case "prune":
+			return pruneCache(entries, args)
+
+		default:
+			return fmt.Errorf("unknown cache subcommand: %q", sub)
+		}
+	},
+	AddFlags: addCacheFlags,
+}
+
+// pruneCache removes the entries selected by the flags of cache prune,
+// using the manifests at paths for -unused-by.
+func pruneCache(entries []vendor.CacheEntry, paths []string) error {
+	if cacheOlderThan == 0 && !cacheUnusedBy {
+		return fmt.Errorf("cache prune needs -older-than or -unused-by")
+	}
+	if len(paths) != 0 && !cacheUnusedBy {
+		return fmt.Errorf("manifests can only be listed with -unused-by")
+	}
+	if cacheUnusedBy && len(paths) == 0 {
+		paths = []string{manifestFile}
+	}
+
+	var manifests []*vendor.Manifest
+	for _, p := range paths {
+		if _, err := os.Stat(p); err != nil {
+			return err
+		}
+		m, err := vendor.ReadManifest(p)
+		if err != nil {
+			return fmt.Errorf("could not load manifest %s: %v", p, err)
+		}
+		manifests = append(manifests, m)
+	}
+
+	var freed int64
+	var removed int
+	for _, e := range entries {
+		if cacheOlderThan != 0 && time.Since(e.LastUsed) < cacheOlderThan {
+			continue
+		}
+		used := false
+		for _, m := range manifests {
+			if e.UsedBy(m) {
+				used = true
+				break
+			}
+		}
+		if used {
+			continue
+		}
+
+		log.Printf("Removing %s (%s)", e.URL, formatSize(e.Size))
+		if err := e.Remove(cmdCtx); err != nil {
+			return fmt.Errorf("could not remove %s: %v", e.Path, err)
+		}
+		freed += e.Size
+		removed++
+	}
+	log.Printf("Removed %d repositories, freed %s", removed, formatSize(freed))
+	return nil
+}
+
+// formatSize returns n bytes in a human readable form.
+func formatSize(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
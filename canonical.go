@@ -0,0 +1,43 @@
+package main
+
+import (
+	"path"
+	"sort"
+	"strings"
+)
+
+// importMismatch is a package that would be vendored at path, while its
+// import comment declares a different canonical import path.
+type importMismatch struct {
+	path, canonical string
+}
+
+// root returns the canonical import path of the dependency at importpath,
+// derived from the mismatch of one of its packages.
+func (mm importMismatch) root(importpath string) string {
+	rel := strings.TrimPrefix(strings.TrimPrefix(mm.path, importpath), "/")
+	if rel == "" || !strings.HasSuffix(mm.canonical, "/"+rel) {
+		return mm.canonical
+	}
+	return strings.TrimSuffix(mm.canonical, "/"+rel)
+}
+
+// canonicalMismatches returns the packages of the dependency at importpath
+// whose import comments, as returned by ParseImportsAndComments, don't match
+// the path they are vendored at, sorted by path.
+func canonicalMismatches(importpath string, comments map[string]string) []importMismatch {
+	var dirs []string
+	for dir := range comments {
+		dirs = append(dirs, dir)
+	}
+	sort.Strings(dirs)
+
+	var mismatches []importMismatch
+	for _, dir := range dirs {
+		p := path.Join(importpath, dir)
+		if comments[dir] != p {
+			mismatches = append(mismatches, importMismatch{path: p, canonical: comments[dir]})
+		}
+	}
+	return mismatches
+}
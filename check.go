@@ -0,0 +1,197 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+
+	"github.com/FiloSottile/gvt/fileutils"
+	"github.com/FiloSottile/gvt/gbvendor"
+)
+
+var (
+	checkFix bool // fix the problems found by check
+)
+
+func addCheckFlags(fs *flag.FlagSet) {
+	fs.BoolVar(&checkFix, "fix", false, "fix the problems that can be fixed safely")
+	fs.BoolVar(&rbInsecure, "precaire", false, "allow the use of insecure protocols")
+}
+
+var cmdCheck = &Command{
+	Name:      "check",
+	UsageLine: "check [-fix] [-precaire]",
+	Short:     "find inconsistencies between the vendor folder and the manifest",
+	Long: `check compares the content of the vendor folder with the manifest.
+
+Each problem found is reported as one of
+
+	missing      the dependency folder does not exist
+	incomplete   the dependency folder contains no files
+	overlapping  the dependency is inside another vendored dependency
+	orphan       the folder or file is not part of any dependency
+	empty        the folder contains no files and no dependencies
+
+check exits with a non-zero status if any problem is found.
+
+Flags:
+	-fix
+		fetch missing and incomplete dependencies again, remove overlapping
+		entries from the manifest and delete empty folders. Orphans are only
+		reported, as they might be code put there on purpose: fetch or delete
+		them by hand.
+	-precaire
+		allow the use of insecure protocols when fetching dependencies again.
+
+`,
+	Run: func(args []string) error {
+		if len(args) != 0 {
+			return fmt.Errorf("check takes no arguments")
+		}
+
+		m, err := vendor.ReadManifest(manifestFile)
+		if err != nil {
+			return fmt.Errorf("could not load manifest: %v", err)
+		}
+
+		problems, err := checkVendor(m)
+		if err != nil {
+			return err
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 1, 2, 1, ' ', 0)
+		for _, p := range problems {
+			fmt.Fprintf(w, "%s\t%s\n", p.status, p.path)
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+
+		if !checkFix {
+			if len(problems) > 0 {
+				return fmt.Errorf("%d problems found", len(problems))
+			}
+			return nil
+		}
+
+		var unfixed uint32
+		rewrite := false
+		for _, p := range problems {
+			switch p.status {
+			case "missing", "incomplete":
+				if err := downloadDependency(p.dep, &unfixed, vendorDir, false); err != nil {
+					log.Printf("%s: %v", p.path, err)
+					unfixed++
+					continue
+				}
+				rewrite = true
+			case "overlapping":
+				log.Printf("removing %s from the manifest", p.path)
+				rewrite = true
+			case "empty":
+				log.Printf("removing empty folder %s", p.path)
+				if err := fileutils.RemoveAll(filepath.Join(vendorDir, filepath.FromSlash(p.path))); err != nil {
+					log.Printf("%s: %v", p.path, err)
+					unfixed++
+				}
+			default:
+				unfixed++
+			}
+		}
+
+		if rewrite {
+			if err := vendor.WriteManifest(manifestFile, m); err != nil {
+				return err
+			}
+		}
+
+		if unfixed > 0 {
+			return fmt.Errorf("%d problems could not be fixed", unfixed)
+		}
+		return nil
+	},
+	AddFlags:   addCheckFlags,
+	LockVendor: true,
+}
+
+type vendorProblem struct {
+	status string
+	path   string // slash separated, relative to vendorDir
+	dep    *vendor.Dependency
+}
+
+// checkVendor returns the problems found comparing the manifest m and the
+// vendor folder, with the statuses documented in cmdCheck.
+func checkVendor(m *vendor.Manifest) ([]vendorProblem, error) {
+	var problems []vendorProblem
+
+	for _, d := range m.Overlapping {
+		problems = append(problems, vendorProblem{status: "overlapping", path: d.Importpath})
+	}
+
+	for i := range m.Dependencies {
+		d := &m.Dependencies[i]
+		dir := filepath.Join(vendorDir, filepath.FromSlash(d.Importpath))
+		if _, err := os.Stat(dir); os.IsNotExist(err) {
+			problems = append(problems, vendorProblem{status: "missing", path: d.Importpath, dep: d})
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		if ok, err := hasFiles(dir); err != nil {
+			return nil, err
+		} else if !ok {
+			problems = append(problems, vendorProblem{status: "incomplete", path: d.Importpath, dep: d})
+		}
+	}
+
+	if _, err := os.Stat(vendorDir); os.IsNotExist(err) {
+		return problems, nil
+	}
+
+	err := filepath.Walk(vendorDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if path == vendorDir {
+			return nil
+		}
+		rel, err := filepath.Rel(vendorDir, path)
+		if err != nil {
+			return err
+		}
+		p := filepath.ToSlash(rel)
+
+		if !info.IsDir() {
+			// files at the top level, like the manifest, are not packages
+			if filepath.Dir(path) != vendorDir {
+				problems = append(problems, vendorProblem{status: "orphan", path: p})
+			}
+			return nil
+		}
+
+		if m.HasImportpath(p) {
+			return filepath.SkipDir
+		}
+		if len(m.GetSubpackages(p)) > 0 {
+			return nil
+		}
+
+		if ok, err := hasFiles(path); err != nil {
+			return err
+		} else if ok {
+			problems = append(problems, vendorProblem{status: "orphan", path: p})
+		} else {
+			problems = append(problems, vendorProblem{status: "empty", path: p})
+		}
+		return filepath.SkipDir
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return problems, nil
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/FiloSottile/gvt/fileutils"
+	"github.com/FiloSottile/gvt/gbvendor"
+)
+
+// legacyTempAge is the minimum age of the temporary folders that don't
+// record the pid of their process for clean to consider them stale.
+const legacyTempAge = time.Hour
+
+var (
+	cleanOlderThan time.Duration // only remove folders older than this
+	cleanDryRun    bool          // only print the folders
+)
+
+func addCleanFlags(fs *flag.FlagSet) {
+	fs.DurationVar(&cleanOlderThan, "older-than", 0, "only remove folders not modified for this long")
+	fs.BoolVar(&cleanDryRun, "n", false, "only print the folders that would be removed")
+}
+
+var cmdClean = &Command{
+	Name:      "clean",
+	UsageLine: "clean [-older-than d] [-n]",
+	Short:     "remove temporary folders left by interrupted runs",
+	Long: `clean removes the temporary working copies that gvt processes which crashed or
+were killed left in the system temporary folder.
+
+gvt names them after the pid of the process that created them, and clean only
+removes the ones whose process is not running anymore. The folders created by
+older versions of gvt don't record the pid, and are removed only once they were
+not modified for an hour.
+
+Flags:
+	-older-than d
+		only remove the folders that were not modified for the duration d,
+		like 24h.
+	-n
+		print the folders that would be removed, without removing them.
+
+`,
+	Run: func(args []string) error {
+		if len(args) != 0 {
+			return fmt.Errorf("clean takes no arguments")
+		}
+		stale, err := staleTempDirs(os.TempDir())
+		if err != nil {
+			return err
+		}
+		var failed int
+		for _, dir := range stale {
+			if cleanDryRun {
+				fmt.Println(dir)
+				continue
+			}
+			log.Println("Removing", dir)
+			if err := fileutils.RemoveAll(dir); err != nil {
+				log.Printf("could not remove %s: %v", dir, err)
+				failed++
+			}
+		}
+		if failed > 0 {
+			return fmt.Errorf("failed to remove %d folders", failed)
+		}
+		return nil
+	},
+	AddFlags: addCleanFlags,
+}
+
+// tempDirRe matches the names of the temporary folders of gvt, as created by
+// the current and older versions.
+var tempDirRe = regexp.MustCompile(`^` + regexp.QuoteMeta(vendor.TempDirPrefix) + `(?:([0-9]+)-)?[0-9]+$`)
+
+// staleTempDirs returns the temporary folders of gvt in dir that were left
+// by processes that are not running anymore.
+func staleTempDirs(dir string) ([]string, error) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var stale []string
+	for _, f := range files {
+		m := tempDirRe.FindStringSubmatch(f.Name())
+		if m == nil || !f.IsDir() {
+			continue
+		}
+		age := time.Since(f.ModTime())
+		if age < cleanOlderThan {
+			continue
+		}
+		if m[1] == "" {
+			if age < legacyTempAge {
+				continue
+			}
+		} else if pid, err := strconv.Atoi(m[1]); err != nil || pid == os.Getpid() || processAlive(pid) {
+			continue
+		}
+		stale = append(stale, filepath.Join(dir, f.Name()))
+	}
+	return stale, nil
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/FiloSottile/gvt/fileutils"
+	"github.com/FiloSottile/gvt/gbvendor"
+)
+
+// copyDependency copies dep from the working copy wc to dst, applying its
+// file filters, and records the checksum of the copied tree in dep.
+// Of a pruned dependency only the recorded packages are copied, and of a
+// flattened one the nested vendor folders are not.
+func copyDependency(dst string, wc vendor.WorkingCopy, dep *vendor.Dependency) error {
+	src := filepath.Join(wc.Dir(), dep.Path)
+
+	if dep.Prune {
+		if err := os.MkdirAll(dst, 0755); err != nil {
+			return err
+		}
+		for _, p := range dep.Packages {
+			p = filepath.FromSlash(p)
+			err := fileutils.CopyPackage(filepath.Join(dst, p), filepath.Join(src, p), !dep.NoTests, dep.AllFiles)
+			if err != nil {
+				fileutils.RemoveAll(dst)
+				return err
+			}
+		}
+	} else if err := fileutils.Copypath(dst, src, !dep.NoTests, dep.AllFiles); err != nil {
+		return err
+	}
+
+	if dep.Flatten {
+		if err := stripNestedVendor(dst); err != nil {
+			return err
+		}
+	}
+
+	if err := fileutils.CopyLicense(dst, wc.Dir()); err != nil {
+		return err
+	}
+
+	sum, err := vendor.Checksum(dst)
+	if err != nil {
+		return err
+	}
+	dep.Checksum = sum
+	return nil
+}
@@ -16,18 +16,19 @@ var (
 
 func addDeleteFlags(fs *flag.FlagSet) {
 	fs.BoolVar(&deleteAll, "all", false, "delete all dependencies")
+	addDryRunFlags(fs)
 }
 
 var cmdDelete = &Command{
 	Name:      "delete",
-	UsageLine: "delete [-all] importpath",
+	UsageLine: "delete [-n [-json]] [-all] importpath",
 	Short:     "delete a local dependency",
 	Long: `delete removes a dependency from the vendor directory and the manifest
 
 Flags:
 	-all
 		remove all dependencies
-
+` + dryRunFlagsDoc + `
 `,
 	Run: func(args []string) error {
 		if len(args) != 1 && !deleteAll {
@@ -41,6 +42,7 @@ Flags:
 			return fmt.Errorf("could not load manifest: %v", err)
 		}
 
+		old := copyManifest(m)
 		var dependencies []vendor.Dependency
 		if deleteAll {
 			dependencies = make([]vendor.Dependency, len(m.Dependencies))
@@ -65,12 +67,19 @@ Flags:
 				return fmt.Errorf("dependency could not be deleted: %v", err)
 			}
 
+			if dryRun {
+				continue
+			}
 			if err := fileutils.RemoveAll(filepath.Join(vendorDir, filepath.FromSlash(path))); err != nil {
 				// TODO(dfc) need to apply vendor.cleanpath here to remove indermediate directories.
 				return fmt.Errorf("dependency could not be deleted: %v", err)
 			}
 		}
+		if dryRun {
+			return printPlan(old, m)
+		}
 		return vendor.WriteManifest(manifestFile, m)
 	},
-	AddFlags: addDeleteFlags,
+	AddFlags:   addDeleteFlags,
+	LockVendor: true,
 }
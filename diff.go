@@ -0,0 +1,230 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/FiloSottile/gvt/gbvendor"
+)
+
+var (
+	diffJSON bool // JSON output
+	diffLog  bool // list upstream commits
+)
+
+func addDiffFlags(fs *flag.FlagSet) {
+	fs.BoolVar(&diffJSON, "json", false, "print the changes as JSON")
+	fs.BoolVar(&diffLog, "log", false, "list the upstream commits between revisions")
+	fs.BoolVar(&insecure, "precaire", false, "allow the use of insecure protocols")
+}
+
+var cmdDiff = &Command{
+	Name:      "diff",
+	UsageLine: "diff [-json] [-log] [-precaire] [old [new]]",
+	Short:     "compare two manifests",
+	Long: `diff reports the dependencies that were added, removed, moved to a new
+revision or otherwise changed between two manifests.
+
+Each of old and new is either a manifest file, or a git revision and a path
+in the form accepted by "git show", like HEAD~1:vendor/manifest. Paths
+starting with ./ are relative to the current directory. If new is not
+supplied the manifest of the current project is used. If old is not
+supplied either, the project manifest at HEAD is used. Manifest files
+supplied as arguments must exist, while the default ones that don't exist
+are read as having no dependencies.
+
+Flags:
+	-json
+		print the changes as a JSON array.
+	-log
+		list the subjects of the upstream commits between the old and the
+		new revision. This requires checking out the repository.
+	-precaire
+		allow the use of insecure protocols.
+
+`,
+	Run: func(args []string) error {
+		oldArg, newArg := "HEAD:./vendor/manifest", manifestFile
+		switch len(args) {
+		case 0:
+		case 1:
+			oldArg = args[0]
+		case 2:
+			oldArg, newArg = args[0], args[1]
+		default:
+			return fmt.Errorf("diff takes at most two manifests")
+		}
+
+		old, err := readManifestArg(oldArg, len(args) > 0)
+		if err != nil {
+			return fmt.Errorf("could not load manifest %s: %v", oldArg, err)
+		}
+		new, err := readManifestArg(newArg, len(args) > 1)
+		if err != nil {
+			return fmt.Errorf("could not load manifest %s: %v", newArg, err)
+		}
+
+		changes := vendor.DiffManifests(old, new)
+		commits := make([][]string, len(changes))
+		if diffLog {
+			for i, c := range changes {
+				if c.Old == nil || c.New == nil || c.Old.Revision == c.New.Revision ||
+					c.Old.Repository != c.New.Repository {
+					continue
+				}
+				commits[i], err = upstreamLog(*c.Old, *c.New)
+				if err != nil {
+					log.Printf("%s: could not list the upstream commits: %v", c.Importpath, err)
+				}
+			}
+		}
+
+		if diffJSON {
+			return printDiffJSON(os.Stdout, changes, commits)
+		}
+		printDiff(os.Stdout, changes, commits)
+		return nil
+	},
+	AddFlags: addDiffFlags,
+}
+
+// readManifestArg reads a manifest from a file, or from a "rev:path"
+// git object. A file that doesn't exist is an empty manifest, unless
+// explicit, as a mistyped argument would otherwise look like one.
+func readManifestArg(arg string, explicit bool) (*vendor.Manifest, error) {
+	if _, err := os.Stat(arg); err == nil || !strings.Contains(arg, ":") {
+		if os.IsNotExist(err) && explicit {
+			return nil, fmt.Errorf("no such file")
+		}
+		return vendor.ReadManifest(arg)
+	}
+
+	rev := arg[:strings.Index(arg, ":")]
+	if err := vendor.RunCmd(cmdCtx, exec.Command("git", "rev-parse", "-q", "--verify", rev+"^{commit}")); err != nil {
+		if _, ok := err.(*exec.ExitError); !ok {
+			return nil, err
+		}
+		return nil, fmt.Errorf("not a file or a git revision")
+	}
+	if err := vendor.RunCmd(cmdCtx, exec.Command("git", "cat-file", "-e", arg)); err != nil {
+		if _, ok := err.(*exec.ExitError); !ok {
+			return nil, err
+		}
+		// the manifest did not exist at that revision
+		return new(vendor.Manifest), nil
+	}
+	var out bytes.Buffer
+	cmd := exec.Command("git", "show", arg)
+	cmd.Stdout = &out
+	cmd.Stderr = os.Stderr
+	if err := vendor.RunCmd(cmdCtx, cmd); err != nil {
+		return nil, err
+	}
+	return vendor.ParseManifest(&out)
+}
+
+// upstreamLog returns the subjects of the commits from old to new.
+func upstreamLog(old, new vendor.Dependency) ([]string, error) {
+	repo, err := dependencyRepo(new, insecure)
+	if err != nil {
+		return nil, err
+	}
+	wc, err := GlobalDownloader.Get(repo, "", "", new.Revision)
+	if err != nil {
+		return nil, err
+	}
+	return wc.Log(cmdCtx, old.Revision, new.Revision)
+}
+
+func printDiff(w io.Writer, changes []vendor.DependencyChange, commits [][]string) {
+	for i, c := range changes {
+		switch {
+		case c.Old == nil:
+			fmt.Fprintf(w, "added    %s at %s\n", c.Importpath, describeRevision(*c.New))
+		case c.New == nil:
+			fmt.Fprintf(w, "removed  %s at %s\n", c.Importpath, describeRevision(*c.Old))
+		default:
+			kind := "changed "
+			if c.Changed("revision") {
+				kind = "updated "
+			}
+			fmt.Fprintf(w, "%s %s\n", kind, c.Importpath)
+			for _, f := range c.Fields() {
+				fmt.Fprintf(w, "\t%s: %s -> %s\n", f.Name, fieldValue(f.Old), fieldValue(f.New))
+			}
+			for _, s := range commits[i] {
+				fmt.Fprintf(w, "\t\t%s\n", s)
+			}
+		}
+	}
+}
+
+func describeRevision(d vendor.Dependency) string {
+	if d.Branch == "" || d.Branch == "HEAD" {
+		return d.Revision
+	}
+	return fmt.Sprintf("%s (%s)", d.Revision, d.Branch)
+}
+
+// fieldValue formats a JSON field value for humans.
+func fieldValue(v json.RawMessage) string {
+	if v == nil {
+		return "(unset)"
+	}
+	var s string
+	if err := json.Unmarshal(v, &s); err == nil {
+		if s == "" {
+			return `""`
+		}
+		return s
+	}
+	return string(v)
+}
+
+type jsonChange struct {
+	Importpath string             `json:"importpath"`
+	Change     string             `json:"change"`
+	Fields     []string           `json:"fields,omitempty"`
+	Old        *vendor.Dependency `json:"old,omitempty"`
+	New        *vendor.Dependency `json:"new,omitempty"`
+	Commits    []string           `json:"commits,omitempty"`
+}
+
+func printDiffJSON(w io.Writer, changes []vendor.DependencyChange, commits [][]string) error {
+	res := []jsonChange{}
+	for i, c := range changes {
+		jc := jsonChange{
+			Importpath: c.Importpath,
+			Old:        c.Old,
+			New:        c.New,
+			Commits:    commits[i],
+		}
+		switch {
+		case c.Old == nil:
+			jc.Change = "added"
+		case c.New == nil:
+			jc.Change = "removed"
+		case c.Changed("revision"):
+			jc.Change = "updated"
+		default:
+			jc.Change = "changed"
+		}
+		for _, f := range c.Fields() {
+			jc.Fields = append(jc.Fields, f.Name)
+		}
+		res = append(res, jc)
+	}
+	b, err := json.MarshalIndent(res, "", "\t")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "%s\n", b)
+	return err
+}
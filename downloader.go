@@ -36,7 +36,8 @@ func init() {
 	GlobalDownloader.reposI = make(map[string]vendor.RemoteRepo)
 }
 
-// Get returns a cached WorkingCopy, or runs RemoteRepo.Checkout
+// Get returns a cached WorkingCopy, or runs RemoteRepo.Checkout and records
+// the use of the repository cache entry it was checked out from
 func (d *Downloader) Get(repo vendor.RemoteRepo, branch, tag, revision string) (vendor.WorkingCopy, error) {
 	key := cacheKey{
 		url: repo.URL(), repoType: repo.Type(),
@@ -54,7 +55,11 @@ func (d *Downloader) Get(repo vendor.RemoteRepo, branch, tag, revision string) (
 	d.wcs[key] = entry
 	d.wcsMu.Unlock()
 
-	entry.v, entry.err = repo.Checkout(branch, tag, revision)
+	entry.v, entry.err = repo.Checkout(cmdCtx, branch, tag, revision)
+	if entry.err == nil {
+		// the last use only informs gvt cache prune, don't fail over it
+		vendor.MarkCacheUsed(repo)
+	}
 	entry.wg.Done()
 	return entry.v, entry.err
 }
@@ -75,6 +80,15 @@ func (d *Downloader) Flush() error {
 	return nil
 }
 
+// SetRemoteRepo makes DeduceRemoteRepo return repo for the import path root
+// and its subpackages.
+func (d *Downloader) SetRemoteRepo(root string, repo vendor.RemoteRepo) {
+	d.reposMu.Lock()
+	d.repos[root] = repo
+	d.reposI[root] = repo
+	d.reposMu.Unlock()
+}
+
 // DeduceRemoteRepo is a cached version of vendor.DeduceRemoteRepo
 func (d *Downloader) DeduceRemoteRepo(path string, insecure bool) (vendor.RemoteRepo, string, error) {
 	cache := d.repos
@@ -92,7 +106,7 @@ func (d *Downloader) DeduceRemoteRepo(path string, insecure bool) (vendor.Remote
 	}
 	d.reposMu.RUnlock()
 
-	repo, extra, err := vendor.DeduceRemoteRepo(path, insecure)
+	repo, extra, err := vendor.DeduceRemoteRepo(cmdCtx, path, insecure)
 	if err != nil {
 		return repo, extra, err
 	}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/FiloSottile/gvt/gbvendor"
+)
+
+var (
+	dryRun     bool // only print the changes
+	dryRunJSON bool // print the changes as JSON
+)
+
+func addDryRunFlags(fs *flag.FlagSet) {
+	fs.BoolVar(&dryRun, "n", false, "print the changes without making them")
+	fs.BoolVar(&dryRunJSON, "json", false, "print the changes as JSON, with -n")
+}
+
+// dryRunFlagsDoc documents the flags added by addDryRunFlags.
+const dryRunFlagsDoc = `	-n
+		resolve the repositories and revisions, and print the changes to the
+		manifest without making them. Neither the vendor folder nor the
+		manifest are modified.
+	-json
+		with -n, print the changes as a JSON array, like gvt diff -json.
+`
+
+// copyManifest returns a copy of m that is not affected by changes
+// to the dependencies of m.
+func copyManifest(m *vendor.Manifest) *vendor.Manifest {
+	c := *m
+	c.Dependencies = append([]vendor.Dependency(nil), m.Dependencies...)
+	return &c
+}
+
+// printPlan prints the changes from old to new, the manifest that a
+// command run with -n would have written.
+func printPlan(old, new *vendor.Manifest) error {
+	changes := vendor.DiffManifests(old, new)
+	commits := make([][]string, len(changes))
+	if dryRunJSON {
+		return printDiffJSON(os.Stdout, changes, commits)
+	}
+	if len(changes) == 0 {
+		log.Println("No changes")
+	}
+	printDiff(os.Stdout, changes, commits)
+	return nil
+}
+
+// restorePlan prints the dependencies that restore would fetch, comparing
+// the manifest with what's in the vendor folder.
+func restorePlan(manFile string) error {
+	m, err := vendor.ReadManifest(manFile)
+	if err != nil {
+		return fmt.Errorf("could not load manifest: %v", err)
+	}
+
+	// the current state of the vendor folder, as a manifest
+	current := new(vendor.Manifest)
+	var errors int
+	for _, dep := range m.Dependencies {
+		if _, err := dependencyRepo(dep, rbInsecure); err != nil {
+			log.Printf("%s: dependency could not be processed: %v", dep.Importpath, err)
+			errors++
+		}
+
+		status, err := verifyDependency(dep)
+		if err != nil {
+			return fmt.Errorf("could not verify %s: %v", dep.Importpath, err)
+		}
+		switch status {
+		case "missing", "incomplete":
+			continue
+		case "modified":
+			dep.Checksum, err = vendor.Checksum(filepath.Join(vendorDir, filepath.FromSlash(dep.Importpath)))
+			if err != nil {
+				return err
+			}
+		}
+		current.Dependencies = append(current.Dependencies, dep)
+	}
+
+	if err := printPlan(current, m); err != nil {
+		return err
+	}
+	if errors > 0 {
+		return fmt.Errorf("failed to resolve %d dependencies", errors)
+	}
+	return nil
+}
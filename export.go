@@ -0,0 +1,272 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/FiloSottile/gvt/gbvendor"
+)
+
+var (
+	exportModule string // module path of the project
+	exportGo     string // go directive version
+	exportForce  bool   // overwrite an existing go.mod
+)
+
+func addExportFlags(fs *flag.FlagSet) {
+	fs.StringVar(&exportModule, "module", "", "module path of the project")
+	fs.StringVar(&exportGo, "go", "1.14", "version for the go directive")
+	fs.BoolVar(&exportForce, "f", false, "overwrite an existing go.mod")
+	fs.BoolVar(&insecure, "precaire", false, "allow the use of insecure protocols")
+}
+
+var cmdExport = &Command{
+	Name:      "export",
+	UsageLine: "export [-module path] [-go version] [-f] [-precaire]",
+	Short:     "generate go.mod and vendor/modules.txt from the manifest",
+	Long: `export translates the manifest into a Go module definition, to help migrating
+a project to modules.
+
+Dependencies are grouped by repository, and each repository becomes a module
+required at the version of the vendored revision: its semver tag if it has
+one, or else a pseudo-version built from its commit time and the closest
+tag, which are read from a checkout of the repository. Versions v2 and
+higher of repositories without a go.mod are marked +incompatible.
+
+It writes go.mod in the current directory, and a vendor/modules.txt listing
+the packages already present in the vendor folder, so that "go build -mod=vendor"
+keeps working with the existing vendored source.
+
+go.sum is not written: run "go mod tidy" afterwards to produce it. It is not
+needed to build with -mod=vendor, but it is when switching away from the
+vendor folder.
+
+Flags:
+	-module path
+		module path of the project. If not supplied, it is derived from
+		the location of the project in GOPATH.
+	-go version
+		version for the go directive in go.mod. Defaults to 1.14, the first
+		version to use the vendor folder automatically.
+	-f
+		overwrite an existing go.mod.
+	-precaire
+		allow the use of insecure protocols.
+
+`,
+	Run: func(args []string) error {
+		if len(args) != 0 {
+			return fmt.Errorf("export takes no arguments")
+		}
+		return export()
+	},
+	AddFlags:   addExportFlags,
+	LockVendor: true,
+}
+
+// exportedModule is a repository of the manifest translated to a module.
+type exportedModule struct {
+	Path     string
+	Version  string
+	Packages []string
+
+	repository, vcs, revision string
+}
+
+func export() error {
+	modPath := exportModule
+	if modPath == "" {
+		modPath = importPath
+	}
+	if modPath == "" {
+		return fmt.Errorf("could not determine the module path of the project, use -module")
+	}
+
+	goMod := filepath.Join(filepath.Dir(vendorDir), "go.mod")
+	if _, err := os.Stat(goMod); err == nil && !exportForce {
+		return fmt.Errorf("%s already exists, use -f to overwrite it", goMod)
+	}
+
+	m, err := vendor.ReadManifest(manifestFile)
+	if err != nil {
+		return fmt.Errorf("could not load manifest: %v", err)
+	}
+	if len(m.Dependencies) == 0 {
+		return fmt.Errorf("no dependencies in the manifest")
+	}
+
+	byRepo := make(map[string]*exportedModule)
+	var modules []*exportedModule
+	for _, dep := range m.Dependencies {
+		root, err := repoRootImportpath(dep)
+		if err != nil {
+			return err
+		}
+		mod := byRepo[dep.Repository]
+		if mod == nil {
+			mod = &exportedModule{
+				Path:       root,
+				repository: dep.Repository,
+				vcs:        dep.VCS,
+				revision:   dep.Revision,
+			}
+			byRepo[dep.Repository] = mod
+			modules = append(modules, mod)
+		} else if mod.Path != root {
+			return fmt.Errorf("%s is vendored under both %s and %s", dep.Repository, mod.Path, root)
+		} else if mod.revision != dep.Revision {
+			return fmt.Errorf("%s is vendored at more than one revision (%s, %s), but a module can only be required at one version",
+				mod.Path, mod.revision, dep.Revision)
+		}
+
+		pkgs, err := vendoredPackages(dep.Importpath)
+		if err != nil {
+			return err
+		}
+		mod.Packages = append(mod.Packages, pkgs...)
+	}
+
+	for _, mod := range modules {
+		log.Println("Resolving:", mod.Path)
+		if err := resolveModuleVersion(mod); err != nil {
+			return fmt.Errorf("%s: %v", mod.Path, err)
+		}
+		sort.Strings(mod.Packages)
+	}
+	sort.Sort(byModulePath(modules))
+
+	var gm bytes.Buffer
+	fmt.Fprintf(&gm, "module %s\n\ngo %s\n\nrequire (\n", modPath, exportGo)
+	for _, mod := range modules {
+		fmt.Fprintf(&gm, "\t%s %s\n", mod.Path, mod.Version)
+	}
+	fmt.Fprintf(&gm, ")\n")
+
+	var mt bytes.Buffer
+	for _, mod := range modules {
+		fmt.Fprintf(&mt, "# %s %s\n## explicit\n", mod.Path, mod.Version)
+		for _, pkg := range mod.Packages {
+			fmt.Fprintln(&mt, pkg)
+		}
+	}
+
+	if err := ioutil.WriteFile(goMod, gm.Bytes(), 0644); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(vendorDir, "modules.txt"), mt.Bytes(), 0644)
+}
+
+// resolveModuleVersion checks out mod and sets its Version.
+func resolveModuleVersion(mod *exportedModule) error {
+	repo, err := vendor.NewRemoteRepo(cmdCtx, mod.repository, mod.vcs, insecure)
+	if err != nil {
+		return fmt.Errorf("could not determine repository: %v", err)
+	}
+	wc, err := GlobalDownloader.Get(repo, "", "", mod.revision)
+	if err != nil {
+		return err
+	}
+
+	declared, err := readModulePath(filepath.Join(wc.Dir(), "go.mod"))
+	if err != nil {
+		return err
+	} else if declared != "" && declared != mod.Path {
+		return fmt.Errorf("the repository go.mod declares module %s, but it is vendored as %s", declared, mod.Path)
+	}
+
+	t, err := wc.CommitTime(cmdCtx)
+	if err != nil {
+		return err
+	}
+	head, merged, err := wc.Tags(cmdCtx)
+	if err != nil {
+		return err
+	}
+	mod.Version, err = vendor.ModuleVersion(mod.Path, t, mod.revision, head, merged, declared != "")
+	return err
+}
+
+// readModulePath returns the module path declared in the go.mod file at
+// path, or the empty string if the file does not exist.
+func readModulePath(path string) (string, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return "", nil
+	} else if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		fields := strings.Fields(s.Text())
+		if len(fields) >= 2 && fields[0] == "module" {
+			return strings.Trim(fields[1], `"`), nil
+		}
+	}
+	return "", s.Err()
+}
+
+// repoRootImportpath returns the import path of the root of the
+// repository dep was fetched from.
+func repoRootImportpath(dep vendor.Dependency) (string, error) {
+	p := strings.Trim(dep.Path, "/")
+	if p == "" {
+		return dep.Importpath, nil
+	}
+	if !strings.HasSuffix(dep.Importpath, "/"+p) {
+		return "", fmt.Errorf("unable to derive the root repo import path of %s", dep.Importpath)
+	}
+	return strings.TrimSuffix(dep.Importpath, "/"+p), nil
+}
+
+// vendoredPackages returns the import paths of the packages present in the
+// vendor folder at or under importpath.
+func vendoredPackages(importpath string) ([]string, error) {
+	root := filepath.Join(vendorDir, filepath.FromSlash(importpath))
+	var pkgs []string
+	seen := make(map[string]bool)
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		name := info.Name()
+		if info.IsDir() {
+			if path != root && (name == "testdata" || name == "vendor" ||
+				strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_")) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if filepath.Ext(name) != ".go" || strings.HasSuffix(name, "_test.go") {
+			return nil
+		}
+		rel, err := filepath.Rel(vendorDir, filepath.Dir(path))
+		if err != nil {
+			return err
+		}
+		if pkg := filepath.ToSlash(rel); !seen[pkg] {
+			seen[pkg] = true
+			pkgs = append(pkgs, pkg)
+		}
+		return nil
+	})
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("%s is not present in the vendor folder, run gvt restore first", importpath)
+	}
+	return pkgs, err
+}
+
+type byModulePath []*exportedModule
+
+func (s byModulePath) Len() int           { return len(s) }
+func (s byModulePath) Less(i, j int) bool { return s[i].Path < s[j].Path }
+func (s byModulePath) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
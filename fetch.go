@@ -1,13 +1,18 @@
 package main
 
 import (
+	"bufio"
 	"flag"
 	"fmt"
+	"go/build"
+	"io/ioutil"
 	"log"
 	"net/url"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
+	"sync"
 
 	"github.com/FiloSottile/gvt/fileutils"
 	"github.com/FiloSottile/gvt/gbvendor"
@@ -21,79 +26,606 @@ var (
 	insecure  bool // Allow the use of insecure protocols
 	tests     bool
 	all       bool
+	prune     bool // vendor only the imported packages
+	flatten   bool // hoist the nested vendor folders
+
+	fetchConnections uint // count of concurrent download connections
+
+	ignoreCanonical bool   // fetch packages at paths other than their canonical ones
+	fetchFile       string // file listing the import paths to fetch
+	version         string // semver constraint on the tag to fetch
+	fetchRepo       string // repository to fetch the import path from
+
+	buildOS   string // GOOS values to scan imports for
+	buildArch string // GOARCH values to scan imports for
+	buildTags string // build tags to scan imports with
 )
 
 func addFetchFlags(fs *flag.FlagSet) {
 	fs.StringVar(&branch, "branch", "", "branch of the package")
 	fs.StringVar(&revision, "revision", "", "revision of the package")
 	fs.StringVar(&tag, "tag", "", "tag of the package")
+	fs.StringVar(&version, "version", "", "semver constraint on the tag of the package")
 	fs.BoolVar(&noRecurse, "no-recurse", false, "do not fetch recursively")
+	fs.UintVar(&fetchConnections, "connections", 8, "count of parallel download connections")
 	fs.BoolVar(&insecure, "precaire", false, "allow the use of insecure protocols")
 	fs.BoolVar(&tests, "t", false, "fetch _test.go files and testdata")
 	fs.BoolVar(&all, "a", false, "fetch all files and subfolders")
+	fs.BoolVar(&prune, "prune", false, "fetch only the imported packages")
+	fs.BoolVar(&flatten, "flatten", false, "hoist the nested vendor folders")
+	fs.BoolVar(&ignoreCanonical, "ignore-canonical", false, "fetch packages at paths other than their canonical ones")
+	fs.StringVar(&fetchFile, "file", "", "file listing the import paths to fetch")
+	fs.StringVar(&fetchRepo, "repo", "", "repository to fetch the import path from")
+	addBuildFilterFlags(fs)
+	addDryRunFlags(fs)
+}
+
+func addBuildFilterFlags(fs *flag.FlagSet) {
+	fs.StringVar(&buildOS, "os", "", "comma separated GOOS values to scan imports for")
+	fs.StringVar(&buildArch, "arch", "", "comma separated GOARCH values to scan imports for")
+	fs.StringVar(&buildTags, "tags", "", "comma separated build tags to scan imports with")
+}
+
+// buildFilterFlagsDoc documents the flags added by addBuildFilterFlags.
+const buildFilterFlagsDoc = `	-os list, -arch list, -tags list
+		only consider the imports of files that would be built for any of
+		the comma separated GOOS and GOARCH values, with the given build tags.
+		If none is supplied all files are considered, including those with
+		an ignore build tag. If only some are, the others default to all the
+		known values and no additional tags.
+`
+
+// buildFilter returns the BuildFilter selected by the flags of
+// addBuildFilterFlags, or nil if none was supplied.
+func buildFilter() *vendor.BuildFilter {
+	if buildOS == "" && buildArch == "" && buildTags == "" {
+		return nil
+	}
+	split := func(s string) []string {
+		return strings.FieldsFunc(s, func(r rune) bool { return r == ',' || r == ' ' })
+	}
+	return &vendor.BuildFilter{
+		OS:   split(buildOS),
+		Arch: split(buildArch),
+		Tags: split(buildTags),
+	}
 }
 
 var cmdFetch = &Command{
 	Name:      "fetch",
-	UsageLine: "fetch [-branch branch] [-revision rev | -tag tag] [-precaire] [-no-recurse] [-t|-a] importpath",
+	UsageLine: "fetch [-branch branch] [-revision rev | -tag tag | -version constraint] [-repo url] [-precaire] [-no-recurse] [-connections N] [-t|-a] [-prune] [-flatten] [-ignore-canonical] [-os list] [-arch list] [-tags list] [-file list] [-n [-json]] importpath...",
 	Short:     "fetch a remote dependency",
-	Long: `fetch vendors an upstream import path.
+	Long: `fetch vendors one or more upstream import paths.
 
-Recursive dependencies are fetched (at their master/tip/HEAD revision), unless they
-or their parent package are already present.
+Recursive dependencies are fetched, unless they or their parent package are already
+present. If a fetched dependency pins their revisions in a lock file (vendor/manifest,
+Godeps/Godeps.json, glide.lock, Gopkg.lock or vendor.conf) those revisions are used,
+otherwise they are fetched at their master/tip/HEAD revision. When more than one lock
+file pins a dependency, the first one read wins, starting with the lock file of the
+import path being fetched.
 
 If a subpackage of a dependency being fetched is already present, it will be deleted.
 
+If a fetched package declares a canonical import path with an import comment, like
+package foo // import "gopkg.in/foo.v1", and is being vendored at a different path,
+fetch refuses and suggests the canonical path instead. Recursive dependencies are
+fetched at the path they are imported by, with a warning.
+
 The import path may include a url scheme. This may be useful when fetching dependencies
 from private repositories that cannot be probed.
 
+Repositories can be redirected to mirrors with rewrite rules, read from the .gvt.conf
+file in the project folder and from the gvt/config file in the user configuration folder
+($XDG_CONFIG_HOME or ~/.config on Linux). Each line is a rule in the form
+
+    github.com/ => https://git.internal/mirror/github.com/
+
+and the import paths and repository URLs starting with the prefix are fetched from
+the replacement instead. The longest matching prefix wins, and the project rules win
+over the user ones. The upstream repositories are never contacted. The manifest records
+both the upstream repository and the mirror when the repository follows from the host,
+like for github.com, and only the mirror otherwise.
+
+When more than one import path is supplied, the repositories are downloaded
+concurrently and the manifest is written once at the end. If any of them fails,
+the manifest is left untouched. The recursive dependencies of each package are
+also downloaded concurrently, but visited one at a time in import path order, so
+the output and the manifest don't depend on which download finishes first.
+
 Flags:
 	-t
 		fetch also _test.go files and testdata.
 	-a
 		fetch all files and subfolders, ignoring ONLY .git, .hg and .bzr.
+	-prune
+		fetch only the packages imported by the project, directly or through
+		other dependencies, plus the repository license. The package at the
+		import path is always fetched. Pruning is recorded in the manifest,
+		and followed by gvt update and gvt restore.
+	-flatten
+		strip the vendor folders of the fetched dependencies, and fetch the
+		packages vendored in them to the top-level vendor folder instead, at
+		the revisions pinned by their lock files. Packages already vendored
+		at a different revision are reported as conflicts. Flattening is
+		recorded in the manifest, and followed by gvt update and gvt restore.
 	-branch branch
 		fetch from the named branch. Will also be used by gvt update.
 		If not supplied the default upstream branch will be used.
 	-no-recurse
 		do not fetch recursively.
+	-connections
+		count of parallel download connections.
 	-tag tag
 		fetch the specified tag.
+	-version constraint
+		fetch the highest tag that is a semantic version matching the constraint,
+		like "^1.4", "~1.4.2", "1.x" or ">=1.2, <1.5". The constraint is recorded
+		in the manifest and used by gvt update. Only supported for git.
 	-revision rev
 		fetch the specific revision from the branch or repository.
 		If no revision supplied, the latest available will be fetched.
+	-repo url
+		fetch the import path from the repository at url instead of the one
+		the import path resolves to. url can also be a file:// URL or an
+		absolute local path, to vendor from a local mirror. The root of the
+		repository is known from the host for github.com and the like, and
+		is otherwise detected by looking for the import path subfolders,
+		resolving the import path upstream only if that is ambiguous.
+	-ignore-canonical
+		fetch the import path even if it's not the canonical import path of
+		its packages, only warning about it.
 	-precaire
 		allow the use of insecure protocols.
-
+	-file list
+		fetch also the import paths listed in the file, one per line.
+		Empty lines and lines starting with # are ignored.
+` + buildFilterFlagsDoc + dryRunFlagsDoc + `
 `,
 	Run: func(args []string) error {
-		switch len(args) {
-		case 0:
+		paths := args
+		if fetchFile != "" {
+			listed, err := readImportpathList(fetchFile)
+			if err != nil {
+				return fmt.Errorf("could not read import paths: %v", err)
+			}
+			paths = append(paths, listed...)
+		}
+		switch {
+		case len(paths) == 0:
 			return fmt.Errorf("fetch: import path missing")
-		case 1:
-			path := args[0]
-			return fetch(path)
-		default:
-			return fmt.Errorf("more than one import path supplied")
+		case len(paths) > 1 && (branch != "" || tag != "" || revision != "" || version != "" || fetchRepo != ""):
+			return fmt.Errorf("-branch, -tag, -revision, -version and -repo can only be used with a single import path")
+		case version != "" && (branch != "" || tag != "" || revision != ""):
+			return fmt.Errorf("-version can't be used with -branch, -tag or -revision")
+		case fetchConnections == 0:
+			return fmt.Errorf("-connections must be at least 1")
 		}
+		return fetch(paths)
 	},
-	AddFlags: addFetchFlags,
+	AddFlags:   addFetchFlags,
+	LockVendor: true,
 }
 
 var (
-	fetchRoot    string   // where the current session started
-	rootRepoURL  string   // the url of the repo from which the root comes from
+	fetchRoots   []string // where the current session started
+	rootRepoURL  string   // the url of the repo from which the current root comes from
 	fetchedToday []string // packages fetched during this session
 )
 
-func fetch(path string) error {
+var (
+	fetchSem   chan struct{} // bounds the concurrent downloads to fetchConnections
+	prefetchWg sync.WaitGroup
+)
+
+func fetch(paths []string) error {
+	fetchSem = make(chan struct{}, fetchConnections)
+	defer func() {
+		// once the fetch is over, failed or not, the prefetches still
+		// pending or running are of no use: cancel them
+		cancelCmd()
+		prefetchWg.Wait()
+	}()
+
 	m, err := vendor.ReadManifest(manifestFile)
 	if err != nil {
 		return fmt.Errorf("could not load manifest: %v", err)
 	}
 
-	fetchRoot = stripscheme(path)
-	return fetchRecursive(m, path, 0)
+	fetchRoots = nil
+	for _, path := range paths {
+		root := stripscheme(path)
+		if m.HasImportpath(root) {
+			return fmt.Errorf("%s or a parent of it is already vendored", root)
+		}
+		fetchRoots = append(fetchRoots, root)
+	}
+
+	var repo vendor.RemoteRepo
+	if fetchRepo != "" {
+		repo, err = vendor.NewRemoteRepo(cmdCtx, fetchRepo, "", insecure)
+	} else if version != "" {
+		repo, _, err = GlobalDownloader.DeduceRemoteRepo(paths[0], insecure)
+	}
+	if err != nil {
+		return err
+	}
+
+	if version != "" {
+		tag, err = latestTag(repo, version)
+		if err != nil {
+			return err
+		}
+		log.Printf("Selected tag %s for %s", tag, version)
+	}
+
+	if fetchRepo != "" {
+		if err := setRootRepo(fetchRoots[0], repo); err != nil {
+			return err
+		}
+	}
+
+	if err := prefetchRoots(paths); err != nil {
+		return err
+	}
+
+	old := copyManifest(m)
+	if err := fetchPaths(m, paths); err != nil {
+		if !dryRun {
+			for _, p := range fetchedToday {
+				fileutils.RemoveAll(filepath.Join(vendorDir, p))
+			}
+			restoreFolders()
+			log.Println("The manifest was left untouched")
+		}
+		return err
+	}
+
+	if dryRun {
+		return printPlan(old, m)
+	}
+	if err := vendor.WriteManifest(manifestFile, m); err != nil {
+		return err
+	}
+	return removeSetAside()
+}
+
+// setAside holds the existing folders that fetchRecursive replaced, moved
+// to temporary folders in the vendor folder until the manifest is written.
+var setAside []struct{ dir, tmp string }
+
+// setAsideFolder moves the folder of path in the vendor folder, if any, out
+// of the way of the fetched dependency, so that it can be restored if the
+// fetch fails.
+func setAsideFolder(path string) error {
+	dir := filepath.Join(vendorDir, filepath.FromSlash(path))
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempDir(vendorDir, ".fetch-")
+	if err != nil {
+		return err
+	}
+	if err := os.Rename(dir, filepath.Join(tmp, "old")); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	setAside = append(setAside, struct{ dir, tmp string }{dir, tmp})
+	return nil
+}
+
+// restoreFolders moves back the folders set aside by setAsideFolder, in
+// reverse order since the later ones might be parents of the earlier ones.
+func restoreFolders() {
+	for i := len(setAside) - 1; i >= 0; i-- {
+		s := setAside[i]
+		fileutils.RemoveAll(s.dir)
+		err := os.MkdirAll(filepath.Dir(s.dir), 0755)
+		if err == nil {
+			err = os.Rename(filepath.Join(s.tmp, "old"), s.dir)
+		}
+		if err != nil {
+			log.Printf("failed to restore %s, its previous content is in %s: %v", s.dir, s.tmp, err)
+			continue
+		}
+		os.Remove(s.tmp)
+	}
+	setAside = nil
+}
+
+// removeSetAside deletes the folders set aside by setAsideFolder.
+func removeSetAside() error {
+	for _, s := range setAside {
+		if err := fileutils.RemoveAll(s.tmp); err != nil {
+			return fmt.Errorf("failed to remove existing folder: %v", err)
+		}
+	}
+	setAside = nil
+	return nil
+}
+
+// fetchPaths fetches paths and their dependencies into m. The imports of
+// pruned dependencies are then fetched until none is missing.
+func fetchPaths(m *vendor.Manifest, paths []string) error {
+	for _, path := range paths {
+		if err := fetchRecursive(m, path, 0); err != nil {
+			return err
+		}
+	}
+	if !hasPruned(m) {
+		return nil
+	}
+
+	for {
+		used, missing, err := usedPackages(m, insecure)
+		if err != nil {
+			return fmt.Errorf("failed to compute the imported packages: %v", err)
+		}
+		if len(missing) == 0 || noRecurse {
+			for _, p := range missing {
+				log.Printf("WARNING: %s is imported but not vendored", p)
+			}
+			return writePruned(m, used)
+		}
+		prefetchImports(m, missing)
+		for _, p := range missing {
+			if err := fetchRecursive(m, p, 1); err != nil {
+				return fmt.Errorf("error fetching %s: %s", p, err)
+			}
+		}
+	}
+}
+
+// prefetchRoots resolves and downloads the repositories of paths
+// concurrently through GlobalDownloader, so that fetchRecursive finds them
+// ready. It reports all the paths that failed.
+func prefetchRoots(paths []string) error {
+	errs := make([]error, len(paths))
+	var wg sync.WaitGroup
+	for i, path := range paths {
+		wg.Add(1)
+		go func(i int, path string) {
+			defer wg.Done()
+			fetchSem <- struct{}{}
+			defer func() { <-fetchSem }()
+
+			repo, _, err := GlobalDownloader.DeduceRemoteRepo(path, insecure)
+			if err != nil {
+				errs[i] = err
+				return
+			}
+			_, errs[i] = GlobalDownloader.Get(repo, branch, tag, revision)
+		}(i, path)
+	}
+	wg.Wait()
+
+	var failed int
+	for i, err := range errs {
+		if err != nil {
+			log.Printf("%s: %v", stripscheme(paths[i]), err)
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("failed to fetch %d import paths, the manifest was left untouched", failed)
+	}
+	return nil
+}
+
+// prefetchImports starts downloading in the background, through
+// GlobalDownloader, the repositories of the imports that fetchRecursive is
+// about to visit. fetchRecursive then finds them ready, or waits for them,
+// while still visiting the imports in order. Errors are left for
+// fetchRecursive to report.
+//
+// The revision of each import is selected when its download starts, not
+// when it is scheduled, so that it includes the pins of the lock files
+// read by fetchRecursive in the meantime.
+func prefetchImports(m *vendor.Manifest, imports []string) {
+	for _, path := range imports {
+		if m.HasImportpath(path) || importPath != "" && contains(importPath, path) {
+			continue
+		}
+		fetched := false
+		for _, p := range fetchedToday {
+			if contains(p, path) {
+				fetched = true
+			}
+		}
+		if fetched {
+			continue
+		}
+		root := rootRepoURL
+
+		prefetchWg.Add(1)
+		go func(path string) {
+			defer prefetchWg.Done()
+			select {
+			case fetchSem <- struct{}{}:
+			case <-cmdCtx.Done():
+				return
+			}
+			defer func() { <-fetchSem }()
+
+			repo, _, err := GlobalDownloader.DeduceRemoteRepo(path, insecure)
+			if err != nil {
+				return
+			}
+			var locked *vendor.LockedDependency
+			if l := findLockedRevision(path); l != nil {
+				locked = &l.LockedDependency
+			}
+			b, t, r := checkoutRevision(repo, root, locked)
+			GlobalDownloader.Get(repo, b, t, r)
+		}(path)
+	}
+}
+
+// download is GlobalDownloader.Get, bounded by fetchSem like the prefetches
+// during a fetch.
+func download(repo vendor.RemoteRepo, branch, tag, revision string) (vendor.WorkingCopy, error) {
+	if fetchSem != nil {
+		fetchSem <- struct{}{}
+		defer func() { <-fetchSem }()
+	}
+	return GlobalDownloader.Get(repo, branch, tag, revision)
+}
+
+// checkoutRevision returns the branch, tag and revision fetchRecursive
+// checks out repo at: the ones requested if it's the repository at rootURL,
+// the revision pinned by locked, or the latest one.
+func checkoutRevision(repo vendor.RemoteRepo, rootURL string, locked *vendor.LockedDependency) (string, string, string) {
+	switch {
+	case repo.URL() == rootURL:
+		return branch, tag, revision
+	case locked != nil:
+		return "", "", locked.Revision
+	}
+	return "", "", ""
+}
+
+// lockedRevision is a revision pinned by the lock file of a fetched dependency.
+type lockedRevision struct {
+	vendor.LockedDependency
+	source string // the lock file it was read from
+}
+
+var (
+	lockedMu        sync.Mutex       // guards lockedRevisions, read by the prefetches
+	lockedRevisions []lockedRevision // revisions pinned by the fetched dependencies
+	lockFilesRead   = make(map[string]bool)
+)
+
+// readLockedRevisions adds the revisions pinned by the lock file in the
+// working copy at dir, if any, to lockedRevisions. Revisions already pinned
+// are not replaced, so that the fetched package wins over its dependencies.
+func readLockedRevisions(dir, name string) error {
+	if lockFilesRead[dir] {
+		return nil
+	}
+	lockFilesRead[dir] = true
+
+	deps, file, err := vendor.ReadLockFile(dir)
+	if err != nil {
+		return err
+	}
+	lockedMu.Lock()
+	defer lockedMu.Unlock()
+	for _, d := range deps {
+		if d.Revision == "" || findLockedRevisionLocked(d.Importpath) != nil {
+			continue
+		}
+		lockedRevisions = append(lockedRevisions, lockedRevision{
+			LockedDependency: d,
+			source:           name + "/" + file,
+		})
+	}
+	return nil
+}
+
+// findLockedRevision returns the pinned revision of the repository that
+// contains path, or nil.
+func findLockedRevision(path string) *lockedRevision {
+	lockedMu.Lock()
+	defer lockedMu.Unlock()
+	return findLockedRevisionLocked(path)
+}
+
+// findLockedRevisionLocked is findLockedRevision for callers holding lockedMu.
+func findLockedRevisionLocked(path string) *lockedRevision {
+	for i, l := range lockedRevisions {
+		if contains(l.Importpath, path) || contains(path, l.Importpath) ||
+			(l.Root != "" && contains(l.Root, path)) {
+			return &lockedRevisions[i]
+		}
+	}
+	return nil
+}
+
+// setRootRepo makes repo the source of importpath. The root of the
+// repository is known from the host of importpath, like for github.com, or
+// else it is the prefix of importpath for which the rest is a subfolder of
+// repo at the requested branch, tag or revision. Only if more than one prefix
+// fits is importpath resolved upstream to pick one, falling back to the
+// longest.
+func setRootRepo(importpath string, repo vendor.RemoteRepo) error {
+	if root, ok := vendor.KnownRepoRoot(importpath); ok {
+		GlobalDownloader.SetRemoteRepo(root, repo)
+		return nil
+	}
+
+	wc, err := download(repo, branch, tag, revision)
+	if err != nil {
+		return err
+	}
+
+	var roots []string // longest first
+	elems := strings.Split(importpath, "/")
+	for i := len(elems) - 1; i >= 1; i-- {
+		rest := filepath.Join(elems[i:]...)
+		if fi, err := os.Stat(filepath.Join(wc.Dir(), rest)); err == nil && fi.IsDir() {
+			roots = append(roots, strings.Join(elems[:i], "/"))
+		}
+	}
+	switch len(roots) {
+	case 0:
+		GlobalDownloader.SetRemoteRepo(importpath, repo)
+		return nil
+	case 1:
+		GlobalDownloader.SetRemoteRepo(roots[0], repo)
+		return nil
+	}
+
+	root := roots[0]
+	if _, extra, err := vendor.DeduceRemoteRepo(cmdCtx, importpath, insecure); err == nil && strings.HasSuffix(importpath, extra) {
+		deduced := strings.Trim(strings.TrimSuffix(importpath, extra), "/")
+		for _, r := range roots {
+			if r == deduced {
+				root = r
+			}
+		}
+	}
+	GlobalDownloader.SetRemoteRepo(root, repo)
+	return nil
+}
+
+// latestTag returns the highest tag of repo matching the semver constraint.
+func latestTag(repo vendor.RemoteRepo, constraint string) (string, error) {
+	c, err := vendor.ParseConstraint(constraint)
+	if err != nil {
+		return "", err
+	}
+	tags, err := repo.Tags(cmdCtx)
+	if err != nil {
+		return "", fmt.Errorf("could not list tags of %s: %v", repo.URL(), err)
+	}
+	t := c.Best(tags)
+	if t == "" {
+		return "", fmt.Errorf("no tag of %s matches %q", repo.URL(), constraint)
+	}
+	return t, nil
+}
+
+// readImportpathList reads the import paths listed in the file at path,
+// one per line, skipping empty lines and # comments.
+func readImportpathList(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var paths []string
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		paths = append(paths, line)
+	}
+	return paths, s.Err()
 }
 
 func fetchRecursive(m *vendor.Manifest, fullPath string, level int) error {
@@ -135,7 +667,7 @@ func fetchRecursive(m *vendor.Manifest, fullPath string, level int) error {
 
 	// Finally, check if we already vendored a subpackage and remove it
 	for _, subp := range m.GetSubpackages(path) {
-		if !contains(subp.Importpath, fetchRoot) { // ignore parents of the root
+		if !containsAny(subp.Importpath, fetchRoots) { // ignore parents of the roots
 			ignore := false
 			for _, d := range fetchedToday {
 				if contains(d, subp.Importpath) {
@@ -150,8 +682,10 @@ func fetchRecursive(m *vendor.Manifest, fullPath string, level int) error {
 			return fmt.Errorf("failed to remove subpackage: %v", err)
 		}
 	}
-	if err := fileutils.RemoveAll(filepath.Join(vendorDir, path)); err != nil && !os.IsNotExist(err) {
-		return fmt.Errorf("failed to remove existing folder: %v", err)
+	if !dryRun {
+		if err := setAsideFolder(path); err != nil {
+			return fmt.Errorf("failed to move existing folder: %v", err)
+		}
 	}
 
 	// Find and download the repository
@@ -165,78 +699,138 @@ func fetchRecursive(m *vendor.Manifest, fullPath string, level int) error {
 		rootRepoURL = repo.URL()
 	}
 
-	var wc vendor.WorkingCopy
-	if repo.URL() == rootRepoURL {
-		wc, err = GlobalDownloader.Get(repo, branch, tag, revision)
-	} else {
-		wc, err = GlobalDownloader.Get(repo, "", "", "")
+	var lockedDep *vendor.LockedDependency
+	locked := findLockedRevision(path)
+	switch {
+	case repo.URL() == rootRepoURL:
+	case locked != nil:
+		logIndent(level, "Using revision", locked.Revision, "pinned by", locked.source)
+		lockedDep = &locked.LockedDependency
+	default:
+		logIndent(level, "Using the latest revision, not pinned by any lock file")
 	}
+	br, tg, rv := checkoutRevision(repo, rootRepoURL, lockedDep)
+	wc, err := download(repo, br, tg, rv)
 	if err != nil {
 		return err
 	}
 
+	if err := readLockedRevisions(wc.Dir(), stripscheme(repo.URL())); err != nil {
+		logIndent(level, "Ignoring lock file:", err)
+	}
+
 	// Add the dependency to the manifest
 
-	rev, err := wc.Revision()
+	rev, err := wc.Revision(cmdCtx)
 	if err != nil {
 		return err
 	}
 
-	b, err := wc.Branch()
+	b, err := wc.Branch(cmdCtx)
 	if err != nil {
 		return err
 	}
+	if repo.URL() != rootRepoURL && locked != nil {
+		// the checkout is detached, keep following the branch of the lock file
+		b = locked.Branch
+	}
 
 	dep := vendor.Dependency{
 		Importpath: path,
 		Repository: repo.URL(),
+		Mirror:     vendor.MirrorURL(repo),
 		VCS:        repo.Type(),
 		Revision:   rev,
 		Branch:     b,
 		Path:       extra,
 		NoTests:    !tests,
 		AllFiles:   all,
+		Prune:      prune,
+		Flatten:    flatten,
 	}
-
-	if err := m.AddDependency(dep); err != nil {
-		return err
+	if repo.URL() == rootRepoURL {
+		dep.Constraint = version
 	}
 
-	// Copy the code to the vendor folder
+	// Scan the packages, checking their canonical import paths
 
 	dst := filepath.Join(vendorDir, dep.Importpath)
 	src := filepath.Join(wc.Dir(), dep.Path)
 
-	if err := fileutils.Copypath(dst, src, !dep.NoTests, dep.AllFiles); err != nil {
-		return err
+	// Look for dependencies in src, not going past wc.Dir() when looking for /vendor/,
+	// knowing that wc.Dir() corresponds to rootRepoPath
+	if !strings.HasSuffix(dep.Importpath, dep.Path) {
+		return fmt.Errorf("unable to derive the root repo import path")
+	}
+	rootRepoPath := strings.TrimRight(strings.TrimSuffix(dep.Importpath, dep.Path), "/")
+	deps, comments, err := vendor.ParseImportsAndComments(src, wc.Dir(), rootRepoPath, tests, all, buildFilter())
+	if err != nil {
+		return fmt.Errorf("failed to parse imports: %s", err)
 	}
 
-	if err := fileutils.CopyLicense(dst, wc.Dir()); err != nil {
-		return err
+	if mismatches := canonicalMismatches(dep.Importpath, comments); len(mismatches) > 0 {
+		if level == 0 && !ignoreCanonical {
+			mm := mismatches[0]
+			return fmt.Errorf("%s has canonical import path %s, fetch %s instead or use -ignore-canonical",
+				mm.path, mm.canonical, mm.root(dep.Importpath))
+		}
+		for _, mm := range mismatches {
+			logIndent(level, "WARNING:", mm.path, "has canonical import path", mm.canonical)
+		}
 	}
 
-	if err := vendor.WriteManifest(manifestFile, m); err != nil {
+	// Copy the code to the vendor folder
+
+	// pruned dependencies are copied by fetchPaths, once all are fetched
+	depSources[path] = wc
+	if !dryRun && !dep.Prune {
+		if err := copyDependency(dst, wc, &dep); err != nil {
+			return err
+		}
+	}
+
+	fetchedToday = append(fetchedToday, path)
+
+	if err := m.AddDependency(dep); err != nil {
 		return err
 	}
 
-	// Recurse
+	// Recurse, unless pruned: then only the imported packages are followed.
+	// The imports are visited in order, while their repositories are
+	// downloaded concurrently.
 
-	fetchedToday = append(fetchedToday, path)
+	if !noRecurse && !dep.Prune {
+		var nested []vendor.LockedDependency
+		if dep.Flatten {
+			// errors were already reported by readLockedRevisions
+			nested, _, _ = vendor.ReadLockFile(wc.Dir())
+		}
 
-	if !noRecurse {
-		// Look for dependencies in src, not going past wc.Dir() when looking for /vendor/,
-		// knowing that wc.Dir() corresponds to rootRepoPath
-		if !strings.HasSuffix(dep.Importpath, dep.Path) {
-			return fmt.Errorf("unable to derive the root repo import path")
+		var imports, remote []string
+		for d := range deps {
+			imports = append(imports, d)
 		}
-		rootRepoPath := strings.TrimRight(strings.TrimSuffix(dep.Importpath, dep.Path), "/")
-		deps, err := vendor.ParseImports(src, wc.Dir(), rootRepoPath, tests, all)
-		if err != nil {
-			return fmt.Errorf("failed to parse imports: %s", err)
+		sort.Strings(imports)
+		for _, d := range imports {
+			if dep.Flatten {
+				d = hoistImport(rootRepoPath, d)
+			}
+			if vendor.IsRemoteImportPath(d) && !vendor.IsStdlibImport(build.Default.GOROOT, d) {
+				remote = append(remote, d)
+			}
 		}
+		prefetchImports(m, remote)
 
-		for d := range deps {
-			if strings.Index(d, ".") == -1 { // TODO: replace this silly heuristic
+		for _, d := range imports {
+			if dep.Flatten {
+				d = hoistImport(rootRepoPath, d)
+				warnConflict(m, level+1, stripscheme(repo.URL()), nested, d)
+			}
+			if vendor.IsStdlibImport(build.Default.GOROOT, d) {
+				continue
+			}
+			if !vendor.IsRemoteImportPath(d) && !(importPath != "" && contains(importPath, d)) {
+				logIndent(level+1, "WARNING: skipping import that is neither in the standard library nor remote:", d)
 				continue
 			}
 			if err := fetchRecursive(m, d, level+1); err != nil {
@@ -271,3 +865,13 @@ func stripscheme(path string) string {
 func contains(a, b string) bool {
 	return a == b || strings.HasPrefix(b, a+"/")
 }
+
+// Package a contains any of packages bs?
+func containsAny(a string, bs []string) bool {
+	for _, b := range bs {
+		if contains(a, b) {
+			return true
+		}
+	}
+	return false
+}
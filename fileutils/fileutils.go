@@ -109,6 +109,37 @@ func Copypath(dst string, src string, tests, all bool) error {
 	return err
 }
 
+// CopyPackage copies the files of the package in src to dst, like Copypath,
+// but without descending into the subfolders of src other than testdata.
+func CopyPackage(dst string, src string, tests, all bool) error {
+	files, err := ioutil.ReadDir(src)
+	if err != nil {
+		return err
+	}
+	for _, info := range files {
+		path := filepath.Join(src, info.Name())
+		if ShouldSkip(path, info, tests, all) {
+			continue
+		}
+
+		dst := filepath.Join(dst, info.Name())
+		switch {
+		case info.Mode()&os.ModeSymlink != 0:
+			err = Copylink(dst, path)
+		case info.IsDir():
+			if info.Name() == "testdata" || info.Name() == "_testdata" {
+				err = Copypath(dst, path, tests, all)
+			}
+		default:
+			err = Copyfile(dst, path)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func Copyfile(dst, src string) error {
 	err := mkdir(filepath.Dir(dst))
 	if err != nil {
@@ -4,6 +4,7 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"reflect"
 	"runtime"
 	"testing"
 )
@@ -34,3 +35,45 @@ func mktemp(t *testing.T) string {
 	}
 	return s
 }
+
+func TestCopyPackage(t *testing.T) {
+	src := mktemp(t)
+	defer RemoveAll(src)
+	for _, name := range []string{
+		"a.go", "a_test.go", "README", "testdata/in.txt", "sub/b.go",
+	} {
+		path := filepath.Join(src, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		tests, all bool
+		want       []string
+	}{
+		{false, false, []string{"a.go"}},
+		{true, false, []string{"a.go", "a_test.go", "testdata/in.txt"}},
+		{false, true, []string{"README", "a.go", "a_test.go", "testdata/in.txt"}},
+	}
+	for _, tt := range tests {
+		dst := mktemp(t)
+		if err := CopyPackage(dst, src, tt.tests, tt.all); err != nil {
+			t.Fatalf("CopyPackage(%s, %s, %v, %v): %v", dst, src, tt.tests, tt.all, err)
+		}
+		var got []string
+		filepath.Walk(dst, func(path string, info os.FileInfo, err error) error {
+			if err == nil && !info.IsDir() {
+				got = append(got, filepath.ToSlash(path[len(dst)+1:]))
+			}
+			return err
+		})
+		RemoveAll(dst)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("CopyPackage(tests: %v, all: %v): got %v, want %v", tt.tests, tt.all, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,33 @@
+package fileutils
+
+import (
+	"errors"
+	"os"
+)
+
+// ErrLocked is returned by TryLock when the lock is already held.
+var ErrLocked = errors.New("lock is held by another process")
+
+// Lock is an exclusive advisory lock backed by a file.
+type Lock struct {
+	path string
+	f    *os.File
+}
+
+// TryLock attempts to acquire an exclusive lock on the file at path, creating
+// it if necessary. If the lock is already held, by this or another process,
+// it returns ErrLocked without blocking.
+//
+// The lock is released when the process exits, even if Unlock is never called.
+func TryLock(path string) (*Lock, error) {
+	f, err := lockFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return &Lock{path: path, f: f}, nil
+}
+
+// Unlock releases the lock and removes the lock file.
+func (l *Lock) Unlock() error {
+	return unlockFile(l.path, l.f)
+}
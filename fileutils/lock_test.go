@@ -0,0 +1,35 @@
+package fileutils
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestTryLock(t *testing.T) {
+	dir := mktemp(t)
+	defer RemoveAll(dir)
+	path := filepath.Join(dir, "lock")
+
+	l, err := TryLock(path)
+	if err != nil {
+		t.Fatalf("TryLock(%s): %v", path, err)
+	}
+	if _, err := TryLock(path); err != ErrLocked {
+		t.Fatalf("TryLock(%s) while held: want %v, got %v", path, ErrLocked, err)
+	}
+	if err := l.Unlock(); err != nil {
+		t.Fatalf("Unlock: %v", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("expected %s to be removed, got %v", path, err)
+	}
+
+	l, err = TryLock(path)
+	if err != nil {
+		t.Fatalf("TryLock(%s) after Unlock: %v", path, err)
+	}
+	if err := l.Unlock(); err != nil {
+		t.Fatalf("Unlock: %v", err)
+	}
+}
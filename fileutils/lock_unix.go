@@ -0,0 +1,51 @@
+//go:build !windows
+// +build !windows
+
+package fileutils
+
+import (
+	"os"
+	"syscall"
+)
+
+func lockFile(path string) (*os.File, error) {
+	for {
+		f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0666)
+		if err != nil {
+			return nil, err
+		}
+		if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+			f.Close()
+			if err == syscall.EWOULDBLOCK {
+				return nil, ErrLocked
+			}
+			return nil, err
+		}
+
+		// The previous holder removes the file on unlock, possibly between our
+		// open and flock. In that case we locked a stale inode: try again.
+		fi, err := f.Stat()
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		cur, err := os.Stat(path)
+		if err == nil && os.SameFile(fi, cur) {
+			return f, nil
+		}
+		f.Close()
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+	}
+}
+
+func unlockFile(path string, f *os.File) error {
+	// Remove the file while still holding the lock, so that any process
+	// waiting on the old inode notices and starts over.
+	err := os.Remove(path)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
@@ -0,0 +1,33 @@
+package fileutils
+
+import (
+	"os"
+	"syscall"
+)
+
+const (
+	errorSharingViolation syscall.Errno = 32
+	fileFlagDeleteOnClose               = 0x04000000
+)
+
+func lockFile(path string) (*os.File, error) {
+	p, err := syscall.UTF16PtrFromString(path)
+	if err != nil {
+		return nil, err
+	}
+	// A share mode of 0 denies any other open of the file until we close it.
+	h, err := syscall.CreateFile(p, syscall.GENERIC_READ|syscall.GENERIC_WRITE, 0, nil,
+		syscall.OPEN_ALWAYS, syscall.FILE_ATTRIBUTE_NORMAL|fileFlagDeleteOnClose, 0)
+	if err == errorSharingViolation {
+		return nil, ErrLocked
+	}
+	if err != nil {
+		return nil, &os.PathError{Op: "open", Path: path, Err: err}
+	}
+	return os.NewFile(uintptr(h), path), nil
+}
+
+func unlockFile(path string, f *os.File) error {
+	// The file is deleted on close.
+	return f.Close()
+}
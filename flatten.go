@@ -0,0 +1,120 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/FiloSottile/gvt/fileutils"
+	"github.com/FiloSottile/gvt/gbvendor"
+)
+
+// hoistImport returns the top-level import path of p, an import of the
+// repository at rootRepoPath, if it resolved to one of its vendor folders.
+func hoistImport(rootRepoPath, p string) string {
+	i := strings.LastIndex(p, "/vendor/")
+	if i < 0 || !contains(rootRepoPath, p[:i]) {
+		return p
+	}
+	return p[i+len("/vendor/"):]
+}
+
+// stripNestedVendor removes the vendor folders from the tree rooted at dir.
+func stripNestedVendor(dir string) error {
+	var nested []string
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() && info.Name() == "vendor" {
+			nested = append(nested, path)
+			return filepath.SkipDir
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, path := range nested {
+		if err := fileutils.RemoveAll(path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// warnConflict warns if path, pinned by the lock file of the repository
+// name to one of the revisions in locked, is vendored at a different one.
+func warnConflict(m *vendor.Manifest, level int, name string, locked []vendor.LockedDependency, path string) {
+	existing, err := m.GetDependencyForImportpath(path)
+	if err != nil {
+		return
+	}
+	for _, l := range locked {
+		if !contains(l.Importpath, path) && !(l.Root != "" && contains(l.Root, path)) {
+			continue
+		}
+		if l.Revision != "" && !strings.HasPrefix(existing.Revision, l.Revision) {
+			logIndent(level, fmt.Sprintf("WARNING: conflict: %s vendors %s at %s, but %s is at %s",
+				name, path, l.Revision, existing.Importpath, existing.Revision))
+		}
+		return
+	}
+}
+
+var (
+	hoistedMu sync.Mutex
+	hoisted   []vendor.Dependency // nested dependencies of flattened ones, found by restore
+)
+
+// hoistNested adds to m the dependencies found in the manifests of the
+// flattened ones by downloadDependency, and restores them, until there are
+// none left. The dependencies already in m are only checked for conflicts.
+func hoistNested(m *vendor.Manifest) (errors uint32) {
+	for len(hoisted) > 0 {
+		deps := hoisted
+		hoisted = nil
+		for _, d := range deps {
+			if existing, err := m.GetDependencyForImportpath(d.Importpath); err == nil {
+				if existing.Revision != d.Revision {
+					log.Printf("WARNING: conflict: %s is vendored at %s, but a nested manifest has %s at %s",
+						existing.Importpath, existing.Revision, d.Importpath, d.Revision)
+				}
+				continue
+			}
+
+			// the checksum of the nested manifest covers a copy made with
+			// its own options, and is recorded again by downloadDependency
+			d.Flatten = true
+			d.Checksum = ""
+			if err := m.AddDependency(d); err != nil {
+				log.Printf("%s: %v", d.Importpath, err)
+				errors++
+				continue
+			}
+			dep := &m.Dependencies[len(m.Dependencies)-1]
+			if err := downloadDependency(dep, &errors, vendorDir, true); err != nil {
+				log.Printf("%s: %v", d.Importpath, err)
+				errors++
+			}
+		}
+	}
+	return errors
+}
+
+// readNestedManifest returns the dependencies of the manifest in the
+// vendor folder of dep, as checked out in wc, if any.
+func readNestedManifest(wc vendor.WorkingCopy, dep *vendor.Dependency) ([]vendor.Dependency, error) {
+	man := filepath.Join(wc.Dir(), dep.Path, "vendor", "manifest")
+	if _, err := os.Stat(man); os.IsNotExist(err) {
+		return nil, nil
+	}
+	m, err := vendor.ReadManifest(man)
+	if err != nil {
+		return nil, fmt.Errorf("could not load manifest: %v", err)
+	}
+	return m.Dependencies, nil
+}
@@ -0,0 +1,79 @@
+package vendor
+
+import (
+	"bytes"
+	"go/build"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+)
+
+// knownOS and knownArch are the GOOS and GOARCH values targeted by
+// a BuildFilter that doesn't restrict them.
+var (
+	knownOS = []string{
+		"aix", "android", "darwin", "dragonfly", "freebsd", "hurd", "illumos", "ios",
+		"js", "linux", "nacl", "netbsd", "openbsd", "plan9", "solaris", "wasip1",
+		"windows", "zos",
+	}
+	knownArch = []string{
+		"386", "amd64", "amd64p32", "arm", "armbe", "arm64", "arm64be", "loong64",
+		"mips", "mipsle", "mips64", "mips64le", "mips64p32", "mips64p32le", "ppc",
+		"ppc64", "ppc64le", "riscv", "riscv64", "s390", "s390x", "sparc", "sparc64",
+		"wasm",
+	}
+)
+
+// BuildFilter selects the files that go/build would include when building
+// for any of a set of targets.
+type BuildFilter struct {
+	// OS and Arch are the targeted GOOS and GOARCH values.
+	// If empty, all known values are targeted.
+	OS, Arch []string
+
+	// Tags are the build tags to consider satisfied, in addition to
+	// the target ones, cgo and the Go release tags.
+	Tags []string
+}
+
+// Match reports whether the Go file name in the directory dir would be
+// built for any of the targets of f. A nil BuildFilter matches all files.
+func (f *BuildFilter) Match(dir, name string) (bool, error) {
+	if f == nil {
+		return true, nil
+	}
+
+	// read the file once, instead of once per target
+	src, err := ioutil.ReadFile(filepath.Join(dir, name))
+	if err != nil {
+		return false, err
+	}
+
+	oses, arches := f.OS, f.Arch
+	if len(oses) == 0 {
+		oses = knownOS
+	}
+	if len(arches) == 0 {
+		arches = knownArch
+	}
+
+	ctx := build.Default
+	ctx.CgoEnabled = true
+	ctx.BuildTags = f.Tags
+	ctx.OpenFile = func(string) (io.ReadCloser, error) {
+		return ioutil.NopCloser(bytes.NewReader(src)), nil
+	}
+	for _, goos := range oses {
+		for _, goarch := range arches {
+			ctx.GOOS, ctx.GOARCH = goos, goarch
+			ok, err := ctx.MatchFile(dir, name)
+			if err != nil {
+				return false, err
+			}
+			if ok {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
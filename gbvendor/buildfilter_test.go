@@ -0,0 +1,58 @@
+package vendor
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/FiloSottile/gvt/fileutils"
+)
+
+func TestBuildFilter(t *testing.T) {
+	dir := mktemp(t)
+	defer fileutils.RemoveAll(dir)
+
+	files := map[string]string{
+		"all.go":         "package p\n",
+		"a_windows.go":   "package p\n",
+		"b_linux_arm.go": "package p\n",
+		"c.go":           "// +build linux darwin\n\npackage p\n",
+		"d.go":           "// +build ignore\n\npackage p\n",
+		"e.go":           "//go:build sometag && !windows\n\npackage p\n",
+		"f_test.go":      "// +build !plan9\n\npackage p\n",
+	}
+	for name, src := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(src), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		filter *BuildFilter
+		want   []string
+	}{
+		{nil, []string{"all.go", "a_windows.go", "b_linux_arm.go", "c.go", "d.go", "e.go", "f_test.go"}},
+		{&BuildFilter{}, []string{"all.go", "a_windows.go", "b_linux_arm.go", "c.go", "f_test.go"}},
+		{&BuildFilter{OS: []string{"linux"}}, []string{"all.go", "b_linux_arm.go", "c.go", "f_test.go"}},
+		{&BuildFilter{OS: []string{"linux"}, Arch: []string{"amd64"}}, []string{"all.go", "c.go", "f_test.go"}},
+		{&BuildFilter{OS: []string{"windows", "plan9"}}, []string{"all.go", "a_windows.go", "f_test.go"}},
+		{&BuildFilter{OS: []string{"windows", "linux"}, Tags: []string{"sometag"}},
+			[]string{"all.go", "a_windows.go", "b_linux_arm.go", "c.go", "e.go", "f_test.go"}},
+	}
+	for i, tt := range tests {
+		var got []string
+		for _, name := range []string{"all.go", "a_windows.go", "b_linux_arm.go", "c.go", "d.go", "e.go", "f_test.go"} {
+			ok, err := tt.filter.Match(dir, name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ok {
+				got = append(got, name)
+			}
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%d: %+v: want %v, got %v", i, tt.filter, tt.want, got)
+		}
+	}
+}
@@ -0,0 +1,331 @@
+package vendor
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/FiloSottile/gvt/fileutils"
+)
+
+// CacheDir is the folder where mirrors of the git and hg repositories are
+// kept across runs. Checkouts are made from the mirrors, which are updated
+// incrementally. If CacheDir is empty, every checkout is a fresh clone.
+var CacheDir string
+
+const (
+	cacheLockTimeout      = 10 * time.Minute
+	cacheLockPollInterval = 200 * time.Millisecond
+	cacheLockNotice       = 2 * time.Second // waiting longer than this is logged
+)
+
+// hashre matches revisions that can't move, so that a mirror containing
+// them doesn't need updating.
+var hashre = regexp.MustCompile(`^[0-9a-f]{7,40}$`)
+
+// cachePath returns the path of the mirror of the vcs repository at repoURL.
+func cachePath(vcs, repoURL string) string {
+	name := repoURL
+	if u, err := url.Parse(repoURL); err == nil && u.Scheme != "" {
+		name = u.Host + u.Path
+	}
+	name = strings.Map(func(r rune) rune {
+		switch {
+		case 'a' <= r && r <= 'z', 'A' <= r && r <= 'Z', '0' <= r && r <= '9':
+		case r == '.', r == '-', r == '_', r == '/':
+		default:
+			return '_'
+		}
+		return r
+	}, name)
+
+	var elems []string
+	for _, e := range strings.Split(name, "/") {
+		if e != "" && e != "." && e != ".." {
+			elems = append(elems, e)
+		}
+	}
+	// the suffix keeps mirrors from being nested into each other
+	return filepath.Join(CacheDir, vcs, filepath.Join(elems...)+"."+vcs)
+}
+
+// lockCachePath acquires the lock on the cache entry at path, waiting up to
+// cacheLockTimeout for other gvt processes, or goroutines, to release it,
+// or until ctx is done.
+func lockCachePath(ctx context.Context, path string) (*fileutils.Lock, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	lockPath := path + ".lock"
+	start := time.Now()
+	waiting := false
+	for {
+		l, err := fileutils.TryLock(lockPath)
+		if err == nil {
+			return l, nil
+		}
+		if err != fileutils.ErrLocked {
+			return nil, fmt.Errorf("could not lock %s: %v", path, err)
+		}
+		if err := ctx.Err(); err != nil {
+			return nil, fmt.Errorf("waiting for the release of %s: %v", lockPath, err)
+		}
+		if time.Since(start) > cacheLockTimeout {
+			return nil, fmt.Errorf("timed out after %v waiting for the release of %s", cacheLockTimeout, lockPath)
+		}
+		if !waiting && time.Since(start) > cacheLockNotice {
+			log.Printf("Waiting for another gvt process to release %s", lockPath)
+			waiting = true
+		}
+		time.Sleep(cacheLockPollInterval)
+	}
+}
+
+// cachedMirror returns the path of the mirror of the vcs repository at
+// repoURL, locked. The caller must release the lock once done with it.
+//
+// If the mirror doesn't exist, clone is run to create it at the given path.
+// Otherwise, update is run in the mirror, unless has reports that it already
+// contains revision.
+func cachedMirror(ctx context.Context, vcs, repoURL, revision string, clone func(dir string) error,
+	update func(dir string) error, has func(dir, revision string) bool) (string, *fileutils.Lock, error) {
+	dir := cachePath(vcs, repoURL)
+	lock, err := lockCachePath(ctx, dir)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		// clone next to it and rename, so that an interrupted clone
+		// doesn't leave a broken mirror
+		tmp := dir + ".new"
+		if err := fileutils.RemoveAll(tmp); err != nil && !os.IsNotExist(err) {
+			lock.Unlock()
+			return "", nil, err
+		}
+		if err := clone(tmp); err != nil {
+			fileutils.RemoveAll(tmp)
+			lock.Unlock()
+			return "", nil, err
+		}
+		if err := os.Rename(tmp, dir); err != nil {
+			lock.Unlock()
+			return "", nil, err
+		}
+	} else if err != nil {
+		lock.Unlock()
+		return "", nil, err
+	} else if !hashre.MatchString(revision) || !has(dir, revision) {
+		if err := update(dir); err != nil {
+			lock.Unlock()
+			return "", nil, fmt.Errorf("could not update the mirror of %s: %v", repoURL, err)
+		}
+	}
+	return dir, lock, nil
+}
+
+// gitMirror is cachedMirror for git repositories.
+func gitMirror(ctx context.Context, repoURL, revision string) (string, *fileutils.Lock, error) {
+	return cachedMirror(ctx, "git", repoURL, revision, func(dir string) error {
+		_, err := run(ctx, "git", "clone", "-q", "--mirror", repoURL, dir)
+		return err
+	}, func(dir string) error {
+		_, err := runPath(ctx, dir, "git", "fetch", "-q", "--prune", "origin")
+		return err
+	}, func(dir, revision string) bool {
+		_, err := runPath(ctx, dir, "git", "rev-parse", "-q", "--verify", revision+"^{commit}")
+		return err == nil
+	})
+}
+
+// hgMirror is cachedMirror for hg repositories.
+func hgMirror(ctx context.Context, repoURL, revision string) (string, *fileutils.Lock, error) {
+	return cachedMirror(ctx, "hg", repoURL, revision, func(dir string) error {
+		return runOut(ctx, os.Stderr, "hg", "clone", "-U", repoURL, dir)
+	}, func(dir string) error {
+		return runOut(ctx, os.Stderr, "hg", "pull", "-q", "-R", dir)
+	}, func(dir, revision string) bool {
+		return runQuiet(ctx, "hg", "-R", dir, "log", "-q", "-r", revision) == nil
+	})
+}
+
+// CacheEntry is a mirror in CacheDir.
+type CacheEntry struct {
+	Path     string    // the folder of the mirror
+	VCS      string    // git or hg
+	URL      string    // the repository the mirror was cloned from
+	Size     int64     // the size in bytes of the files in the mirror
+	LastUsed time.Time // the last time a checkout was made from the mirror
+}
+
+// MarkCacheUsed records that a checkout was just made from the mirror of
+// repo, if it has one.
+func MarkCacheUsed(repo RemoteRepo) error {
+	if CacheDir == "" {
+		return nil
+	}
+	if m, ok := repo.(*mirrorRepo); ok {
+		repo = m.RemoteRepo
+	}
+	var dir string
+	switch r := repo.(type) {
+	case *gitrepo:
+		dir = cachePath("git", r.url)
+	case *hgrepo:
+		dir = cachePath("hg", r.url)
+	default:
+		return nil
+	}
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		return nil
+	}
+	stamp := time.Now().UTC().Format(time.RFC3339) + "\n"
+	return ioutil.WriteFile(dir+".used", []byte(stamp), 0644)
+}
+
+// CacheEntries returns the mirrors in CacheDir, sorted by path.
+func CacheEntries(ctx context.Context) ([]CacheEntry, error) {
+	var entries []CacheEntry
+	for _, vcs := range []string{"git", "hg"} {
+		root := filepath.Join(CacheDir, vcs)
+		err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+			if os.IsNotExist(err) && path == root {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			if !info.IsDir() || !strings.HasSuffix(path, "."+vcs) {
+				return nil
+			}
+			e, err := readCacheEntry(ctx, path, vcs, info)
+			if err != nil {
+				return fmt.Errorf("%s: %v", path, err)
+			}
+			entries = append(entries, e)
+			return filepath.SkipDir
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	sort.Sort(byPath(entries))
+	return entries, nil
+}
+
+func readCacheEntry(ctx context.Context, path, vcs string, info os.FileInfo) (CacheEntry, error) {
+	e := CacheEntry{Path: path, VCS: vcs, LastUsed: info.ModTime()}
+
+	var out []byte
+	var err error
+	switch vcs {
+	case "git":
+		out, err = run(ctx, "git", "--git-dir", path, "config", "--get", "remote.origin.url")
+	case "hg":
+		out, err = run(ctx, "hg", "-R", path, "paths", "default")
+	}
+	if err != nil {
+		return e, fmt.Errorf("could not read the repository URL: %v", err)
+	}
+	e.URL = strings.TrimSpace(string(out))
+
+	err = filepath.Walk(path, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.Mode().IsRegular() {
+			e.Size += info.Size()
+		}
+		return nil
+	})
+	if err != nil {
+		return e, err
+	}
+
+	// mirrors created before last uses were recorded fall back to the mtime
+	if b, err := ioutil.ReadFile(path + ".used"); err == nil {
+		if t, err := time.Parse(time.RFC3339, strings.TrimSpace(string(b))); err == nil {
+			e.LastUsed = t
+		}
+	}
+	return e, nil
+}
+
+// UsedBy reports whether any dependency in m is checked out from the mirror,
+// from its repository, recorded mirror or the rewrite of its repository.
+func (e CacheEntry) UsedBy(m *Manifest) bool {
+	for _, d := range m.Dependencies {
+		if d.VCS != "" && d.VCS != e.VCS {
+			continue
+		}
+		urls := []string{d.Repository, d.Mirror}
+		if u, err := url.Parse(d.Repository); err == nil && !filepath.IsAbs(d.Repository) {
+			urls = append(urls, Rewrites.Rewrite(u.Host+u.Path))
+		}
+		for _, u := range urls {
+			if u != "" && cachePath(e.VCS, u) == e.Path {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Verify runs the integrity checks of the VCS on the mirror.
+func (e CacheEntry) Verify(ctx context.Context) error {
+	lock, err := lockCachePath(ctx, e.Path)
+	if err != nil {
+		return err
+	}
+	defer lock.Unlock()
+
+	var cmd *exec.Cmd
+	switch e.VCS {
+	case "git":
+		cmd = exec.Command("git", "--git-dir", e.Path, "fsck", "--no-progress")
+	case "hg":
+		cmd = exec.Command("hg", "-R", e.Path, "verify", "-q")
+	default:
+		return fmt.Errorf("unknown repository type: %q", e.VCS)
+	}
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+	if err := runCmd(ctx, cmd); err != nil {
+		return fmt.Errorf("%v\n%s", err, bytes.TrimSpace(out.Bytes()))
+	}
+	return nil
+}
+
+// Remove deletes the mirror from the cache.
+func (e CacheEntry) Remove(ctx context.Context) error {
+	lock, err := lockCachePath(ctx, e.Path)
+	if err != nil {
+		return err
+	}
+	defer lock.Unlock()
+
+	if err := fileutils.RemoveAll(e.Path); err != nil {
+		return err
+	}
+	if err := os.Remove(e.Path + ".used"); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+type byPath []CacheEntry
+
+func (s byPath) Len() int           { return len(s) }
+func (s byPath) Less(i, j int) bool { return s[i].Path < s[j].Path }
+func (s byPath) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
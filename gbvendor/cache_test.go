@@ -0,0 +1,170 @@
+package vendor
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/FiloSottile/gvt/fileutils"
+)
+
+func TestCachePath(t *testing.T) {
+	defer func(dir string) { CacheDir = dir }(CacheDir)
+	CacheDir = "/cache"
+
+	tests := []struct {
+		vcs, url string
+		want     string
+	}{
+		{"git", "https://github.com/pkg/sftp", "/cache/git/github.com/pkg/sftp.git"},
+		{"git", "https://github.com/pkg/sftp.git", "/cache/git/github.com/pkg/sftp.git.git"},
+		{"hg", "https://bitbucket.org/ww/goautoneg", "/cache/hg/bitbucket.org/ww/goautoneg.hg"},
+		{"git", "git@github.com:pkg/sftp", "/cache/git/git_github.com_pkg/sftp.git"},
+		{"git", "/srv/../repos/./sftp", "/cache/git/srv/repos/sftp.git"},
+		{"git", "file:///srv/repos/sftp", "/cache/git/srv/repos/sftp.git"},
+	}
+	for _, tt := range tests {
+		if got := cachePath(tt.vcs, tt.url); got != filepath.FromSlash(tt.want) {
+			t.Errorf("cachePath(%q, %q): want %s, got %s", tt.vcs, tt.url, tt.want, got)
+		}
+	}
+}
+
+func TestGitCheckoutCache(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not found")
+	}
+	defer func(dir string) { CacheDir = dir }(CacheDir)
+	CacheDir = mktemp(t)
+	defer fileutils.RemoveAll(CacheDir)
+
+	upstream := mktemp(t)
+	defer fileutils.RemoveAll(upstream)
+	git := func(args ...string) {
+		args = append([]string{"-c", "user.name=gvt", "-c", "user.email=gvt@example.com"}, args...)
+		cmd := exec.Command("git", args...)
+		cmd.Dir = upstream
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	git("init", "-q")
+	git("commit", "-q", "--allow-empty", "-m", "first")
+
+	repo := &gitrepo{url: upstream}
+	wc, err := repo.Checkout(context.Background(), "", "", "")
+	if err != nil {
+		t.Fatalf("Checkout: %v", err)
+	}
+	rev, err := wc.Revision(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	wc.Destroy()
+	if _, err := os.Stat(cachePath("git", upstream)); err != nil {
+		t.Fatalf("mirror not created: %v", err)
+	}
+
+	// a revision in the mirror is checked out without reaching upstream
+	if err := fileutils.RemoveAll(upstream); err != nil {
+		t.Fatal(err)
+	}
+	wc, err = repo.Checkout(context.Background(), "", "", rev)
+	if err != nil {
+		t.Fatalf("Checkout(%s) from the mirror: %v", rev, err)
+	}
+	if got, _ := wc.Revision(context.Background()); got != rev {
+		t.Errorf("Checkout(%s) from the mirror: got revision %s", rev, got)
+	}
+	wc.Destroy()
+
+	// the latest revision requires updating the mirror
+	if _, err := repo.Checkout(context.Background(), "", "", ""); err == nil {
+		t.Errorf("Checkout of the latest revision succeeded without upstream")
+	}
+}
+
+func TestCacheEntries(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not found")
+	}
+	defer func(dir string) { CacheDir = dir }(CacheDir)
+	CacheDir = mktemp(t)
+	defer fileutils.RemoveAll(CacheDir)
+
+	entries, err := CacheEntries(context.Background())
+	if err != nil || len(entries) != 0 {
+		t.Fatalf("CacheEntries of an empty cache: want no entries, got %v, %v", entries, err)
+	}
+
+	upstream := mktemp(t)
+	defer fileutils.RemoveAll(upstream)
+	cmd := exec.Command("git", "-c", "user.name=gvt", "-c", "user.email=gvt@example.com",
+		"commit", "-q", "--allow-empty", "-m", "first")
+	cmd.Dir = upstream
+	if out, err := exec.Command("git", "init", "-q", upstream).CombinedOutput(); err != nil {
+		t.Fatalf("git init: %v\n%s", err, out)
+	}
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("git commit: %v\n%s", err, out)
+	}
+
+	repo := &gitrepo{url: upstream}
+	wc, err := repo.Checkout(context.Background(), "", "", "")
+	if err != nil {
+		t.Fatalf("Checkout: %v", err)
+	}
+	wc.Destroy()
+	before := time.Now().Add(-time.Second)
+	if err := MarkCacheUsed(repo); err != nil {
+		t.Fatalf("MarkCacheUsed: %v", err)
+	}
+
+	entries, err = CacheEntries(context.Background())
+	if err != nil {
+		t.Fatalf("CacheEntries: %v", err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("CacheEntries: want 1 entry, got %v", entries)
+	}
+	e := entries[0]
+	if e.Path != cachePath("git", upstream) || e.VCS != "git" || e.URL != upstream {
+		t.Errorf("CacheEntries: unexpected entry %+v", e)
+	}
+	if e.Size == 0 {
+		t.Errorf("CacheEntries: entry has no size")
+	}
+	if e.LastUsed.Before(before) {
+		t.Errorf("CacheEntries: last use %v is older than %v", e.LastUsed, before)
+	}
+
+	tests := []struct {
+		deps []Dependency
+		want bool
+	}{
+		{[]Dependency{{Repository: upstream, VCS: "git"}}, true},
+		{[]Dependency{{Repository: upstream}}, true},
+		{[]Dependency{{Repository: upstream, VCS: "hg"}}, false},
+		{[]Dependency{{Repository: "https://example.com/canonical", Mirror: upstream}}, true},
+		{[]Dependency{{Repository: "https://example.com/other", VCS: "git"}}, false},
+	}
+	for _, tt := range tests {
+		if got := e.UsedBy(&Manifest{Dependencies: tt.deps}); got != tt.want {
+			t.Errorf("UsedBy(%+v): want %v, got %v", tt.deps, tt.want, got)
+		}
+	}
+
+	if err := e.Verify(context.Background()); err != nil {
+		t.Errorf("Verify: %v", err)
+	}
+	if err := e.Remove(context.Background()); err != nil {
+		t.Fatalf("Remove: %v", err)
+	}
+	entries, err = CacheEntries(context.Background())
+	if err != nil || len(entries) != 0 {
+		t.Fatalf("CacheEntries after Remove: want no entries, got %v, %v", entries, err)
+	}
+}
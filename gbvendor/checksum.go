@@ -0,0 +1,69 @@
+package vendor
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// Checksum returns a hash of all the files in the tree rooted at dir.
+//
+// The format is the same "h1:" hash used by go.sum: the base64 of the SHA-256
+// of the sorted list of "<hex SHA-256 of file>  <slash separated path>\n"
+// lines. Symbolic links are hashed by their target.
+//
+// The vendor folder at the root of dir is skipped, as restore fills it from
+// the nested manifest of the dependency after the hash is recorded.
+func Checksum(dir string) (string, error) {
+	var lines []string
+	nested := filepath.Join(dir, "vendor")
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if path == nested {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		h := sha256.New()
+		if info.Mode()&os.ModeSymlink != 0 {
+			target, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			io.WriteString(h, target)
+		} else {
+			f, err := os.Open(path)
+			if err != nil {
+				return err
+			}
+			_, err = io.Copy(h, f)
+			f.Close()
+			if err != nil {
+				return err
+			}
+		}
+		lines = append(lines, fmt.Sprintf("%x  %s\n", h.Sum(nil), filepath.ToSlash(rel)))
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	sort.Strings(lines)
+	h := sha256.New()
+	for _, l := range lines {
+		io.WriteString(h, l)
+	}
+	return "h1:" + base64.StdEncoding.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,44 @@
+package vendor
+
+import (
+	"testing"
+
+	"github.com/FiloSottile/gvt/fileutils"
+)
+
+func TestChecksum(t *testing.T) {
+	root := mktemp(t)
+	defer fileutils.RemoveAll(root)
+
+	checksum := func() string {
+		sum, err := Checksum(root)
+		if err != nil {
+			t.Fatalf("Checksum(%s): %v", root, err)
+		}
+		return sum
+	}
+
+	writeFile(t, root, "a.go", "hello\n")
+	writeFile(t, root, "sub/b.go", "package sub\n")
+
+	want := "h1:oBUYrvM5KOW7+BkPa3yd5ChTqo+G9mrVP89Hqdm5W9k="
+	if got := checksum(); got != want {
+		t.Fatalf("Checksum: want %s, got %s", want, got)
+	}
+
+	writeFile(t, root, "vendor/x/x.go", "package x\n")
+	if got := checksum(); got != want {
+		t.Fatalf("Checksum changed after adding a file to the vendor folder")
+	}
+
+	writeFile(t, root, "sub/b.go", "package sub // edited\n")
+	if got := checksum(); got == want {
+		t.Fatalf("Checksum did not change after editing a file")
+	}
+
+	writeFile(t, root, "sub/b.go", "package sub\n")
+	writeFile(t, root, "c.go", "")
+	if got := checksum(); got == want {
+		t.Fatalf("Checksum did not change after adding a file")
+	}
+}
@@ -0,0 +1,112 @@
+package vendor
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"sort"
+)
+
+// DependencyChange describes how a dependency differs between two manifests.
+type DependencyChange struct {
+	Importpath string
+
+	// Old is nil if the dependency was added, and New is nil if it was removed.
+	Old, New *Dependency
+}
+
+// FieldChange is a manifest field of a dependency that changed, identified by
+// its JSON name. Old or New are nil if the field was not set.
+type FieldChange struct {
+	Name     string
+	Old, New json.RawMessage
+}
+
+// DiffManifests returns the dependencies that differ between old and new,
+// ordered by import path.
+func DiffManifests(old, new *Manifest) []DependencyChange {
+	changes := make(map[string]*DependencyChange)
+	for i, d := range old.Dependencies {
+		changes[d.Importpath] = &DependencyChange{Importpath: d.Importpath, Old: &old.Dependencies[i]}
+	}
+	for i, d := range new.Dependencies {
+		if c, ok := changes[d.Importpath]; ok {
+			c.New = &new.Dependencies[i]
+		} else {
+			changes[d.Importpath] = &DependencyChange{Importpath: d.Importpath, New: &new.Dependencies[i]}
+		}
+	}
+
+	var paths []string
+	for p, c := range changes {
+		if c.Old != nil && c.New != nil && reflect.DeepEqual(*c.Old, *c.New) {
+			continue
+		}
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+
+	var res []DependencyChange
+	for _, p := range paths {
+		res = append(res, *changes[p])
+	}
+	return res
+}
+
+// Fields returns the fields that differ between Old and New, including
+// unknown fields, ordered by name. It returns nil for added or removed
+// dependencies.
+func (c DependencyChange) Fields() []FieldChange {
+	if c.Old == nil || c.New == nil {
+		return nil
+	}
+	old, new := dependencyFields(*c.Old), dependencyFields(*c.New)
+
+	var names []string
+	for name := range old {
+		names = append(names, name)
+	}
+	for name := range new {
+		if _, ok := old[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	var res []FieldChange
+	for _, name := range names {
+		if !bytes.Equal(old[name], new[name]) {
+			res = append(res, FieldChange{Name: name, Old: old[name], New: new[name]})
+		}
+	}
+	return res
+}
+
+// Changed reports whether the field with the given JSON name changed.
+func (c DependencyChange) Changed(name string) bool {
+	for _, f := range c.Fields() {
+		if f.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func dependencyFields(d Dependency) map[string]json.RawMessage {
+	b, err := json.Marshal(d)
+	if err != nil {
+		panic(err) // a Dependency can always be encoded
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(b, &fields); err != nil {
+		panic(err)
+	}
+	// Compact the values so that only semantic changes are reported
+	for name, v := range fields {
+		var buf bytes.Buffer
+		if err := json.Compact(&buf, v); err == nil {
+			fields[name] = buf.Bytes()
+		}
+	}
+	return fields
+}
@@ -0,0 +1,75 @@
+package vendor
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestDiffManifests(t *testing.T) {
+	old := &Manifest{Dependencies: []Dependency{{
+		Importpath: "github.com/foo/bar",
+		Repository: "https://github.com/foo/bar",
+		Revision:   "abcdef",
+		Branch:     "master",
+	}, {
+		Importpath: "github.com/foo/removed",
+		Repository: "https://github.com/foo/removed",
+		Revision:   "abcdef",
+		Branch:     "master",
+	}, {
+		Importpath: "github.com/foo/same",
+		Repository: "https://github.com/foo/same",
+		Revision:   "abcdef",
+		Branch:     "master",
+		Extra:      map[string]json.RawMessage{"note": json.RawMessage(`"x"`)},
+	}}}
+	new := &Manifest{Dependencies: []Dependency{{
+		Importpath: "github.com/foo/added",
+		Repository: "https://github.com/foo/added",
+		Revision:   "123456",
+		Branch:     "master",
+	}, {
+		Importpath: "github.com/foo/bar",
+		Repository: "https://github.com/foo/bar",
+		Revision:   "123456",
+		Branch:     "dev",
+		NoTests:    true,
+	}, {
+		Importpath: "github.com/foo/same",
+		Repository: "https://github.com/foo/same",
+		Revision:   "abcdef",
+		Branch:     "master",
+		Extra:      map[string]json.RawMessage{"note": json.RawMessage(`"x"`)},
+	}}}
+
+	changes := DiffManifests(old, new)
+	var paths []string
+	for _, c := range changes {
+		paths = append(paths, c.Importpath)
+	}
+	want := []string{"github.com/foo/added", "github.com/foo/bar", "github.com/foo/removed"}
+	if !reflect.DeepEqual(paths, want) {
+		t.Fatalf("DiffManifests: want %v, got %v", want, paths)
+	}
+
+	if changes[0].Old != nil || changes[0].New == nil {
+		t.Errorf("%s: expected an addition", changes[0].Importpath)
+	}
+	if changes[2].Old == nil || changes[2].New != nil {
+		t.Errorf("%s: expected a removal", changes[2].Importpath)
+	}
+
+	fields := changes[1].Fields()
+	wantFields := []FieldChange{
+		{Name: "branch", Old: json.RawMessage(`"master"`), New: json.RawMessage(`"dev"`)},
+		{Name: "notests", Old: nil, New: json.RawMessage(`true`)},
+		{Name: "revision", Old: json.RawMessage(`"abcdef"`), New: json.RawMessage(`"123456"`)},
+	}
+	if !reflect.DeepEqual(fields, wantFields) {
+		t.Errorf("Fields: want %s, got %s", wantFields, fields)
+	}
+	if !changes[1].Changed("revision") || changes[1].Changed("repository") {
+		t.Errorf("Changed: wrong result for %s", changes[1].Importpath)
+	}
+}
@@ -0,0 +1,151 @@
+package vendor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrInterrupted is returned by the VCS commands killed by Interrupt, or
+// started after it.
+var ErrInterrupted = errors.New("interrupted")
+
+// CommandTimeout is the maximum duration of each VCS command. If zero,
+// commands are only bounded by the context they are run with.
+var CommandTimeout time.Duration
+
+// TimeoutError is returned by the VCS commands killed because they exceeded
+// CommandTimeout or the deadline of their context.
+type TimeoutError struct {
+	Command string
+}
+
+func (e *TimeoutError) Error() string {
+	return fmt.Sprintf("timed out running %s", e.Command)
+}
+
+// IsTimeout reports whether err is a *TimeoutError.
+func IsTimeout(err error) bool {
+	_, ok := err.(*TimeoutError)
+	return ok
+}
+
+var (
+	procsMu     sync.Mutex
+	procs       = make(map[*exec.Cmd]bool) // the running VCS commands
+	interrupted bool
+)
+
+// runCmd runs cmd non-interactively, keeping track of it so that Interrupt
+// can kill it, and killing it when ctx is done or CommandTimeout expires.
+func runCmd(ctx context.Context, cmd *exec.Cmd) error {
+	if CommandTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, CommandTimeout)
+		defer cancel()
+	}
+	if err := ctx.Err(); err != nil {
+		return ctxError(ctx, cmd)
+	}
+	nonInteractive(cmd)
+
+	procsMu.Lock()
+	if interrupted {
+		procsMu.Unlock()
+		return ErrInterrupted
+	}
+	setProcessGroup(cmd)
+	if err := cmd.Start(); err != nil {
+		procsMu.Unlock()
+		return err
+	}
+	procs[cmd] = true
+	procsMu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			killProcess(cmd)
+		case <-done:
+		}
+	}()
+	err := cmd.Wait()
+	close(done)
+
+	procsMu.Lock()
+	defer procsMu.Unlock()
+	delete(procs, cmd)
+	switch {
+	case interrupted:
+		return ErrInterrupted
+	case err != nil && ctx.Err() != nil:
+		return ctxError(ctx, cmd)
+	}
+	return err
+}
+
+// RunCmd runs cmd like the VCS commands of this package: non-interactively,
+// killed by Interrupt, when ctx is done or when CommandTimeout expires.
+func RunCmd(ctx context.Context, cmd *exec.Cmd) error {
+	return runCmd(ctx, cmd)
+}
+
+// ctxError returns the error of cmd once ctx is done.
+func ctxError(ctx context.Context, cmd *exec.Cmd) error {
+	if ctx.Err() == context.DeadlineExceeded {
+		return &TimeoutError{Command: strings.Join(cmd.Args, " ")}
+	}
+	return ctx.Err()
+}
+
+// nonInteractive makes cmd fail instead of prompting for credentials, which
+// would hang with nobody to answer them.
+func nonInteractive(cmd *exec.Cmd) {
+	env := append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+	if !customSSH() {
+		env = append(env, "GIT_SSH_COMMAND=ssh -o BatchMode=yes")
+	}
+	cmd.Env = env
+
+	if cmd.Args[0] == "hg" {
+		cmd.Args = append([]string{"hg", "--noninteractive"}, cmd.Args[1:]...)
+	}
+}
+
+var (
+	customSSHOnce sync.Once
+	customSSHSet  bool
+)
+
+// customSSH reports whether git is set up to run a custom ssh command, with
+// GIT_SSH, GIT_SSH_COMMAND or core.sshCommand, which must not be overridden.
+func customSSH() bool {
+	customSSHOnce.Do(func() {
+		if os.Getenv("GIT_SSH") != "" || os.Getenv("GIT_SSH_COMMAND") != "" {
+			customSSHSet = true
+			return
+		}
+		// git config exits with 1 if the key is not set
+		out, _ := exec.Command("git", "config", "core.sshCommand").Output()
+		customSSHSet = strings.TrimSpace(string(out)) != ""
+	})
+	return customSSHSet
+}
+
+// Interrupt kills the running VCS commands, and makes the ones started
+// afterwards fail, so that the working copies they were creating can be
+// destroyed. It's meant to be called once gvt is about to exit.
+func Interrupt() {
+	procsMu.Lock()
+	defer procsMu.Unlock()
+	interrupted = true
+	for cmd := range procs {
+		killProcess(cmd)
+	}
+}
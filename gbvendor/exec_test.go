@@ -0,0 +1,117 @@
+package vendor
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/FiloSottile/gvt/fileutils"
+)
+
+func TestInterrupt(t *testing.T) {
+	if _, err := exec.LookPath("sleep"); err != nil {
+		t.Skip("sleep not found")
+	}
+	defer func() {
+		procsMu.Lock()
+		interrupted = false
+		procsMu.Unlock()
+	}()
+
+	errc := make(chan error)
+	go func() { errc <- runQuiet(context.Background(), "sleep", "10") }()
+	for {
+		procsMu.Lock()
+		n := len(procs)
+		procsMu.Unlock()
+		if n > 0 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	Interrupt()
+	select {
+	case err := <-errc:
+		if err != ErrInterrupted {
+			t.Errorf("running command: want %v, got %v", ErrInterrupted, err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("running command was not killed")
+	}
+	if err := runQuiet(context.Background(), "sleep", "0"); err != ErrInterrupted {
+		t.Errorf("command started after Interrupt: want %v, got %v", ErrInterrupted, err)
+	}
+}
+
+func TestCommandTimeout(t *testing.T) {
+	if _, err := exec.LookPath("sleep"); err != nil {
+		t.Skip("sleep not found")
+	}
+	defer func(d time.Duration) { CommandTimeout = d }(CommandTimeout)
+	CommandTimeout = 50 * time.Millisecond
+
+	start := time.Now()
+	if err := runQuiet(context.Background(), "sleep", "10"); !IsTimeout(err) {
+		t.Errorf("command exceeding CommandTimeout: want a timeout error, got %v", err)
+	}
+	if d := time.Since(start); d > 5*time.Second {
+		t.Errorf("command exceeding CommandTimeout was killed after %v", d)
+	}
+
+	CommandTimeout = 0
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := runQuiet(ctx, "sleep", "10"); !IsTimeout(err) {
+		t.Errorf("command exceeding the context deadline: want a timeout error, got %v", err)
+	}
+	if err := runQuiet(context.Background(), "sleep", "0"); err != nil {
+		t.Errorf("command within the timeout: %v", err)
+	}
+}
+
+func TestNonInteractiveKeepsSSHCommand(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not found")
+	}
+	home := mktemp(t)
+	defer fileutils.RemoveAll(home)
+	writeFile(t, home, ".gitconfig", "[core]\n\tsshCommand = ssh -i /keys/deploy\n")
+
+	for _, v := range []string{"HOME", "XDG_CONFIG_HOME", "GIT_CONFIG_NOSYSTEM", "GIT_SSH", "GIT_SSH_COMMAND"} {
+		defer func(v, old string) { os.Setenv(v, old) }(v, os.Getenv(v))
+	}
+	os.Setenv("HOME", home)
+	os.Setenv("XDG_CONFIG_HOME", home)
+	os.Setenv("GIT_CONFIG_NOSYSTEM", "1")
+	os.Unsetenv("GIT_SSH")
+	os.Unsetenv("GIT_SSH_COMMAND")
+
+	sshCommand := func() string {
+		customSSHOnce = sync.Once{}
+		defer func() { customSSHOnce = sync.Once{} }()
+		cmd := exec.Command("git", "version")
+		nonInteractive(cmd)
+		for _, e := range cmd.Env {
+			if strings.HasPrefix(e, "GIT_SSH_COMMAND=") {
+				return e
+			}
+		}
+		return ""
+	}
+
+	if got := sshCommand(); got != "" {
+		t.Errorf("core.sshCommand is set, but got %s", got)
+	}
+	if err := os.Remove(filepath.Join(home, ".gitconfig")); err != nil {
+		t.Fatal(err)
+	}
+	if got := sshCommand(); got != "GIT_SSH_COMMAND=ssh -o BatchMode=yes" {
+		t.Errorf("expected ssh in BatchMode, got %q", got)
+	}
+}
@@ -0,0 +1,20 @@
+//go:build !windows
+// +build !windows
+
+package vendor
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// setProcessGroup makes cmd the leader of a new process group, so that
+// killProcess reaches the helpers it starts, like git-remote-https.
+func setProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
+
+// killProcess kills the process group of cmd, started by runCmd.
+func killProcess(cmd *exec.Cmd) error {
+	return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+}
@@ -0,0 +1,10 @@
+package vendor
+
+import "os/exec"
+
+func setProcessGroup(cmd *exec.Cmd) {}
+
+// killProcess kills cmd, started by runCmd.
+func killProcess(cmd *exec.Cmd) error {
+	return cmd.Process.Kill()
+}
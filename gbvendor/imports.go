@@ -1,7 +1,9 @@
 package vendor
 
 import (
+	"context"
 	"fmt"
+	"go/ast"
 	"go/parser"
 	"go/token"
 	"io"
@@ -11,7 +13,9 @@ import (
 	"os"
 	"path"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/FiloSottile/gvt/fileutils"
 )
@@ -19,8 +23,23 @@ import (
 // ParseImports parses Go packages from a specific root returning a set of import paths.
 // vendorRoot is how deep to go looking for vendor folders, usually the repo root.
 // vendorPrefix is the vendorRoot import path.
-func ParseImports(root, vendorRoot, vendorPrefix string, tests, all bool) (map[string]bool, error) {
+// Only the files matched by filter are parsed. A nil filter matches all files.
+func ParseImports(root, vendorRoot, vendorPrefix string, tests, all bool, filter *BuildFilter) (map[string]bool, error) {
+	pkgs, _, err := ParseImportsAndComments(root, vendorRoot, vendorPrefix, tests, all, filter)
+	return pkgs, err
+}
+
+// ParseImportsAndComments is like ParseImports, but also returns the canonical
+// import paths declared by import comments, like
+//
+//	package foo // import "example.com/foo"
+//
+// keyed by the folder of the package relative to root, in slash form.
+// Like the go tool, it ignores the import comments of test files and of
+// packages in vendor folders.
+func ParseImportsAndComments(root, vendorRoot, vendorPrefix string, tests, all bool, filter *BuildFilter) (map[string]bool, map[string]string, error) {
 	pkgs := make(map[string]bool)
+	comments := make(map[string]string)
 
 	var walkFn = func(p string, info os.FileInfo, err error) error {
 		if err != nil {
@@ -34,35 +53,119 @@ func ParseImports(root, vendorRoot, vendorPrefix string, tests, all bool) (map[s
 			return nil
 		}
 
-		if info.IsDir() || filepath.Ext(p) != ".go" {
+		if info.IsDir() {
 			return nil
 		}
 
-		fs := token.NewFileSet()
-		f, err := parser.ParseFile(fs, p, nil, parser.ImportsOnly)
+		comment, err := parseFileImports(pkgs, p, vendorRoot, vendorPrefix, filter)
+		if err != nil || comment == "" || strings.HasSuffix(p, "_test.go") {
+			return err
+		}
+		dir, err := filepath.Rel(root, filepath.Dir(p))
 		if err != nil {
 			return err
 		}
-
-		for _, s := range f.Imports {
-			pkg := strings.Replace(s.Path.Value, "\"", "", -1)
-			if strings.HasPrefix(pkg, "./") {
-				middle, err := filepath.Rel(vendorRoot, filepath.Dir(p))
-				if err != nil {
-					panic(err)
-				}
-				pkg = path.Join(vendorPrefix, middle, pkg)
-			}
-			if vp := findVendor(vendorRoot, filepath.Dir(p), pkg); vp != "" {
-				pkg = path.Join(vendorPrefix, vp)
+		dir = filepath.ToSlash(dir)
+		for _, elem := range strings.Split(dir, "/") {
+			if elem == "vendor" {
+				return nil
 			}
-			pkgs[pkg] = true
+		}
+		if _, ok := comments[dir]; !ok {
+			comments[dir] = comment
 		}
 		return nil
 	}
 
 	err := filepath.Walk(root, walkFn)
-	return pkgs, err
+	return pkgs, comments, err
+}
+
+// ParsePackageImports is like ParseImports, but only parses the files of the
+// package in dir, without descending into its subfolders.
+func ParsePackageImports(dir, vendorRoot, vendorPrefix string, tests, all bool, filter *BuildFilter) (map[string]bool, error) {
+	pkgs := make(map[string]bool)
+
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, info := range files {
+		p := filepath.Join(dir, info.Name())
+		if info.IsDir() || fileutils.ShouldSkip(p, info, tests, all) {
+			continue
+		}
+		if _, err := parseFileImports(pkgs, p, vendorRoot, vendorPrefix, filter); err != nil {
+			return nil, err
+		}
+	}
+	return pkgs, nil
+}
+
+// parseFileImports adds to pkgs the imports of the file at p, if it's a Go
+// file matched by filter, and returns its import comment.
+func parseFileImports(pkgs map[string]bool, p, vendorRoot, vendorPrefix string, filter *BuildFilter) (string, error) {
+	if filepath.Ext(p) != ".go" {
+		return "", nil
+	}
+
+	if ok, err := filter.Match(filepath.Dir(p), filepath.Base(p)); err != nil {
+		return "", err
+	} else if !ok {
+		return "", nil
+	}
+
+	fs := token.NewFileSet()
+	f, err := parser.ParseFile(fs, p, nil, parser.ImportsOnly|parser.ParseComments)
+	if err != nil {
+		return "", err
+	}
+
+	for _, s := range f.Imports {
+		pkg := strings.Replace(s.Path.Value, "\"", "", -1)
+		if strings.HasPrefix(pkg, "./") {
+			middle, err := filepath.Rel(vendorRoot, filepath.Dir(p))
+			if err != nil {
+				panic(err)
+			}
+			pkg = path.Join(vendorPrefix, middle, pkg)
+		}
+		if vp := findVendor(vendorRoot, filepath.Dir(p), pkg); vp != "" {
+			pkg = path.Join(vendorPrefix, vp)
+		}
+		pkgs[pkg] = true
+	}
+	return importComment(fs, f), nil
+}
+
+// importComment returns the import path in the import comment of f, which
+// must be on the same line as the package clause, or the empty string.
+func importComment(fs *token.FileSet, f *ast.File) string {
+	line := fs.Position(f.Name.End()).Line
+	for _, g := range f.Comments {
+		if g.Pos() < f.Name.End() {
+			continue
+		}
+		if fs.Position(g.Pos()).Line != line {
+			break
+		}
+		text := g.List[0].Text
+		if strings.HasPrefix(text, "//") {
+			text = text[2:]
+		} else {
+			text = strings.TrimSuffix(text[2:], "*/")
+		}
+		text = strings.TrimSpace(text)
+		if !strings.HasPrefix(text, "import ") {
+			return ""
+		}
+		path, err := strconv.Unquote(strings.TrimSpace(text[len("import "):]))
+		if err != nil {
+			return ""
+		}
+		return path
+	}
+	return ""
 }
 
 // findVendor looks for pkgName in a vendor folder at start/vendor or deeper, stopping
@@ -101,30 +204,38 @@ func findVendor(root, start, pkgName string) string {
 	}
 }
 
+// metadataClient fetches the go-get metadata. Unlike the VCS commands,
+// requests are always bounded, as a stalled server would hang gvt.
+var metadataClient = &http.Client{Timeout: 30 * time.Second}
+
 // FetchMetadata fetchs the remote metadata for path.
-func FetchMetadata(path string, insecure bool) (rc io.ReadCloser, err error) {
+func FetchMetadata(ctx context.Context, path string, insecure bool) (rc io.ReadCloser, err error) {
 	defer func() {
 		if err != nil {
 			err = fmt.Errorf("unable to determine remote metadata protocol: %s", err)
 		}
 	}()
 	// try https first
-	rc, err = fetchMetadata("https", path)
+	rc, err = fetchMetadata(ctx, "https", path)
 	if err == nil {
 		return
 	}
 	// try http if supported
 	if insecure {
-		rc, err = fetchMetadata("http", path)
+		rc, err = fetchMetadata(ctx, "http", path)
 	}
 	return
 }
 
-func fetchMetadata(scheme, path string) (io.ReadCloser, error) {
+func fetchMetadata(ctx context.Context, scheme, path string) (io.ReadCloser, error) {
 	url := fmt.Sprintf("%s://%s?go-get=1", scheme, path)
 	switch scheme {
 	case "https", "http":
-		resp, err := http.Get(url)
+		req, err := http.NewRequest("GET", url, nil)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := metadataClient.Do(req.WithContext(ctx))
 		if err != nil {
 			return nil, fmt.Errorf("failed to access url %q", url)
 		}
@@ -135,8 +246,8 @@ func fetchMetadata(scheme, path string) (io.ReadCloser, error) {
 }
 
 // ParseMetadata fetchs and decodes remote metadata for path.
-func ParseMetadata(path string, insecure bool) (string, string, string, error) {
-	rc, err := FetchMetadata(path, insecure)
+func ParseMetadata(ctx context.Context, path string, insecure bool) (string, string, string, error) {
+	rc, err := FetchMetadata(ctx, path, insecure)
 	if err != nil {
 		return "", "", "", err
 	}
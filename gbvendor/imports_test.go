@@ -2,10 +2,13 @@ package vendor
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"io"
 	"reflect"
 	"testing"
+
+	"github.com/FiloSottile/gvt/fileutils"
 )
 
 func TestFetchMetadata(t *testing.T) {
@@ -48,7 +51,7 @@ go get gopkg.in/check.v1
 	}}
 
 	for _, tt := range tests {
-		r, err := FetchMetadata(tt.path, tt.insecure)
+		r, err := FetchMetadata(context.Background(), tt.path, tt.insecure)
 		if err != nil {
 			t.Error(err)
 			continue
@@ -78,7 +81,7 @@ go get gopkg.in/check.v1
 	}}
 
 	for _, ett := range errTests {
-		r, err := FetchMetadata(ett.path, ett.insecure)
+		r, err := FetchMetadata(context.Background(), ett.path, ett.insecure)
 		if err == nil {
 			t.Errorf("Access to url %q without any error, but the error should be happen.", ett.path)
 			if r != nil {
@@ -125,7 +128,7 @@ func TestParseMetadata(t *testing.T) {
 	}}
 
 	for _, tt := range tests {
-		importpath, vcs, reporoot, err := ParseMetadata(tt.path, tt.insecure)
+		importpath, vcs, reporoot, err := ParseMetadata(context.Background(), tt.path, tt.insecure)
 		if !reflect.DeepEqual(err, tt.err) {
 			t.Error(err)
 			continue
@@ -135,3 +138,54 @@ func TestParseMetadata(t *testing.T) {
 		}
 	}
 }
+
+func TestParsePackageImports(t *testing.T) {
+	root := mktemp(t)
+	defer fileutils.RemoveAll(root)
+
+	writeFile(t, root, "a.go", "package a\nimport (\n\"fmt\"\n\"example.com/x\"\n)\n")
+	writeFile(t, root, "a_test.go", "package a\nimport \"testing\"\n")
+	writeFile(t, root, "sub/b.go", "package sub\nimport \"os\"\n")
+	writeFile(t, root, "vendor/example.com/x/x.go", "package x\nimport \"net\"\n")
+
+	tests := []struct {
+		tests bool
+		want  map[string]bool
+	}{
+		{false, map[string]bool{"fmt": true, "example.com/a/vendor/example.com/x": true}},
+		{true, map[string]bool{"fmt": true, "example.com/a/vendor/example.com/x": true, "testing": true}},
+	}
+	for _, tt := range tests {
+		got, err := ParsePackageImports(root, root, "example.com/a", tt.tests, false, nil)
+		if err != nil {
+			t.Fatalf("ParsePackageImports(tests: %v): %v", tt.tests, err)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParsePackageImports(tests: %v): got %v, want %v", tt.tests, got, tt.want)
+		}
+	}
+}
+
+func TestParseImportsAndComments(t *testing.T) {
+	root := mktemp(t)
+	defer fileutils.RemoveAll(root)
+
+	writeFile(t, root, "a.go", "package a // import \"example.com/canonical\"\n")
+	writeFile(t, root, "a_test.go", "package a // import \"example.com/test\"\n")
+	writeFile(t, root, "sub/b.go", "package sub /* import \"example.com/canonical/sub\" */\n")
+	writeFile(t, root, "c/c.go", "package c // not an import comment\n")
+	writeFile(t, root, "d/d.go", "package d\n\n// import \"example.com/d\"\n")
+	writeFile(t, root, "vendor/x/x.go", "package x // import \"x.org/x\"\n")
+
+	_, got, err := ParseImportsAndComments(root, root, "example.com/a", true, false, nil)
+	if err != nil {
+		t.Fatalf("ParseImportsAndComments: %v", err)
+	}
+	want := map[string]string{
+		".":   "example.com/canonical",
+		"sub": "example.com/canonical/sub",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseImportsAndComments: got %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,350 @@
+package vendor
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// LockedDependency is a package pinned by the lock file of a vendoring tool.
+type LockedDependency struct {
+	// Importpath is the import path of the pinned package.
+	Importpath string
+
+	// Root is the import path of the root of the repository the package
+	// comes from. Can be blank if the lock file does not record it.
+	Root string
+
+	// Repository and VCS are the location and type of the repository,
+	// if the lock file overrides them.
+	Repository string
+	VCS        string
+
+	// Revision is the pinned revision. Some formats allow tags here.
+	Revision string
+
+	// Branch is the branch Revision was taken from, if known.
+	Branch string
+}
+
+// RepositoryPath returns Repository if the lock file recorded it as an
+// import path, like github.com/fork/repo, as dep and vndr allow, and the
+// empty string if it is a URL or a local path.
+func (l LockedDependency) RepositoryPath() string {
+	if l.Repository == "" || strings.Contains(l.Repository, "://") || filepath.IsAbs(l.Repository) {
+		return ""
+	}
+	return l.Repository
+}
+
+// ParseLockFile parses the dependencies pinned by a Godeps.json, glide.lock,
+// Gopkg.lock or vendor.conf file. The format is detected by the file name.
+func ParseLockFile(p string) ([]LockedDependency, error) {
+	var parse func(io.Reader) ([]LockedDependency, error)
+	switch filepath.Base(p) {
+	case "Godeps.json":
+		parse = parseGodeps
+	case "glide.lock":
+		parse = parseGlideLock
+	case "Gopkg.lock":
+		parse = parseGopkgLock
+	case "vendor.conf":
+		parse = parseVendorConf
+	default:
+		return nil, fmt.Errorf("unknown lock file format: %s", p)
+	}
+
+	f, err := os.Open(p)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	deps, err := parse(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", p, err)
+	}
+	return deps, nil
+}
+
+// LockFiles are the paths, relative to the root of a repository, of the
+// lock files read by ReadLockFile, in order of preference.
+var LockFiles = []string{
+	"vendor/manifest",
+	"Godeps/Godeps.json",
+	"glide.lock",
+	"Gopkg.lock",
+	"vendor.conf",
+}
+
+// ReadLockFile parses the first of LockFiles found in the repository checked
+// out at dir, and returns its relative path. If there is none, it returns
+// a blank path and no dependencies.
+func ReadLockFile(dir string) ([]LockedDependency, string, error) {
+	for _, name := range LockFiles {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		if _, err := os.Stat(p); os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return nil, "", err
+		}
+
+		if name != "vendor/manifest" {
+			deps, err := ParseLockFile(p)
+			return deps, name, err
+		}
+
+		m, err := ReadManifest(p)
+		if err != nil {
+			return nil, "", fmt.Errorf("%s: %v", p, err)
+		}
+		var deps []LockedDependency
+		for _, d := range m.Dependencies {
+			deps = append(deps, LockedDependency{
+				Importpath: d.Importpath,
+				Root:       strings.TrimSuffix(d.Importpath, d.Path),
+				Repository: d.Repository,
+				VCS:        d.VCS,
+				Revision:   d.Revision,
+				Branch:     d.Branch,
+			})
+		}
+		return deps, name, nil
+	}
+	return nil, "", nil
+}
+
+// parseGodeps parses a godep Godeps/Godeps.json file.
+func parseGodeps(r io.Reader) ([]LockedDependency, error) {
+	var godeps struct {
+		Deps []struct {
+			ImportPath string
+			Rev        string
+		}
+	}
+	if err := json.NewDecoder(r).Decode(&godeps); err != nil {
+		return nil, err
+	}
+	var deps []LockedDependency
+	for _, d := range godeps.Deps {
+		deps = append(deps, LockedDependency{
+			Importpath: d.ImportPath,
+			Revision:   d.Rev,
+		})
+	}
+	return deps, nil
+}
+
+// parseGlideLock parses a glide.lock file. It only understands the subset of
+// YAML that glide writes.
+func parseGlideLock(r io.Reader) ([]LockedDependency, error) {
+	var deps []LockedDependency
+	var cur *LockedDependency
+	var subpackages []string
+	inImports, inSubpackages := false, false
+
+	flush := func() {
+		if cur == nil {
+			return
+		}
+		if len(subpackages) == 0 {
+			subpackages = []string{"."}
+		}
+		for _, sp := range subpackages {
+			d := *cur
+			d.Importpath = path.Join(cur.Root, sp)
+			deps = append(deps, d)
+		}
+		cur, subpackages = nil, nil
+	}
+
+	s := bufio.NewScanner(r)
+	for n := 1; s.Scan(); n++ {
+		line := s.Text()
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+
+		if !strings.HasPrefix(line, " ") && !strings.HasPrefix(line, "-") {
+			// top level key
+			flush()
+			inImports = trimmed == "imports:" || trimmed == "testImports:"
+			continue
+		}
+		if !inImports {
+			continue
+		}
+
+		if strings.HasPrefix(line, "- ") {
+			flush()
+			cur = &LockedDependency{}
+			inSubpackages = false
+			trimmed = strings.TrimSpace(trimmed[2:])
+		} else if cur == nil {
+			return nil, fmt.Errorf("line %d: unexpected %q", n, trimmed)
+		}
+
+		if inSubpackages && strings.HasPrefix(trimmed, "- ") {
+			subpackages = append(subpackages, yamlValue(trimmed[2:]))
+			continue
+		}
+		inSubpackages = false
+
+		i := strings.Index(trimmed, ":")
+		if i < 0 {
+			return nil, fmt.Errorf("line %d: unexpected %q", n, trimmed)
+		}
+		key, value := trimmed[:i], yamlValue(trimmed[i+1:])
+		switch key {
+		case "name":
+			cur.Root = value
+		case "version":
+			cur.Revision = value
+		case "repo":
+			cur.Repository = value
+		case "vcs":
+			cur.VCS = value
+		case "subpackages":
+			inSubpackages = true
+		}
+	}
+	flush()
+	return deps, s.Err()
+}
+
+func yamlValue(s string) string {
+	s = strings.TrimSpace(s)
+	if uq, err := strconv.Unquote(s); err == nil {
+		return uq
+	}
+	return strings.Trim(s, "'")
+}
+
+// parseGopkgLock parses a dep Gopkg.lock file. It only understands the subset
+// of TOML that dep writes.
+func parseGopkgLock(r io.Reader) ([]LockedDependency, error) {
+	type project struct {
+		name, revision, branch, source string
+		packages                       []string
+	}
+	var projects []*project
+	var cur *project
+
+	s := bufio.NewScanner(r)
+	for n := 1; s.Scan(); n++ {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") {
+			cur = nil
+			if line == "[[projects]]" {
+				cur = &project{}
+				projects = append(projects, cur)
+			}
+			continue
+		}
+		if cur == nil {
+			continue
+		}
+
+		i := strings.Index(line, "=")
+		if i < 0 {
+			return nil, fmt.Errorf("line %d: unexpected %q", n, line)
+		}
+		key, value := strings.TrimSpace(line[:i]), strings.TrimSpace(line[i+1:])
+
+		if strings.HasPrefix(value, "[") {
+			// arrays might span multiple lines
+			for !strings.HasSuffix(value, "]") && s.Scan() {
+				n++
+				value += strings.TrimSpace(s.Text())
+			}
+			var values []string
+			for _, v := range strings.Split(strings.Trim(value, "[]"), ",") {
+				if v = strings.TrimSpace(v); v != "" {
+					values = append(values, tomlString(v))
+				}
+			}
+			if key == "packages" {
+				cur.packages = values
+			}
+			continue
+		}
+
+		switch key {
+		case "name":
+			cur.name = tomlString(value)
+		case "revision":
+			cur.revision = tomlString(value)
+		case "branch":
+			cur.branch = tomlString(value)
+		case "source":
+			cur.source = tomlString(value)
+		}
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+
+	var deps []LockedDependency
+	for _, p := range projects {
+		if len(p.packages) == 0 {
+			p.packages = []string{"."}
+		}
+		for _, pkg := range p.packages {
+			deps = append(deps, LockedDependency{
+				Importpath: path.Join(p.name, pkg),
+				Root:       p.name,
+				Repository: p.source,
+				Revision:   p.revision,
+				Branch:     p.branch,
+			})
+		}
+	}
+	return deps, nil
+}
+
+func tomlString(s string) string {
+	if uq, err := strconv.Unquote(s); err == nil {
+		return uq
+	}
+	return s
+}
+
+// parseVendorConf parses a vndr or trash vendor.conf file, made of lines
+// like "importpath revision [repository]".
+func parseVendorConf(r io.Reader) ([]LockedDependency, error) {
+	var deps []LockedDependency
+	s := bufio.NewScanner(r)
+	for n := 1; s.Scan(); n++ {
+		line := s.Text()
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) < 2 {
+			return nil, fmt.Errorf("line %d: missing revision", n)
+		}
+		d := LockedDependency{
+			Importpath: fields[0],
+			Root:       fields[0],
+			Revision:   fields[1],
+		}
+		if len(fields) > 2 {
+			d.Repository = fields[2]
+		}
+		deps = append(deps, d)
+	}
+	return deps, s.Err()
+}
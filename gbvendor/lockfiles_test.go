@@ -0,0 +1,248 @@
+package vendor
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/FiloSottile/gvt/fileutils"
+)
+
+func TestParseLockFiles(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want []LockedDependency
+	}{{
+		name: "Godeps.json",
+		in: `{
+	"ImportPath": "github.com/example/project",
+	"GoVersion": "go1.7",
+	"Deps": [
+		{
+			"ImportPath": "github.com/pkg/errors",
+			"Comment": "v0.8.0",
+			"Rev": "645ef00459ed84a119197bfb8d8205042c6df63d"
+		},
+		{
+			"ImportPath": "golang.org/x/net/context",
+			"Rev": "f2499483f923065a842d38eb4c7f1927e6fc6e6d"
+		}
+	]
+}`,
+		want: []LockedDependency{{
+			Importpath: "github.com/pkg/errors",
+			Revision:   "645ef00459ed84a119197bfb8d8205042c6df63d",
+		}, {
+			Importpath: "golang.org/x/net/context",
+			Revision:   "f2499483f923065a842d38eb4c7f1927e6fc6e6d",
+		}},
+	}, {
+		name: "glide.lock",
+		in: `hash: 0a1b2c
+updated: 2017-01-20T16:04:05.000000000-08:00
+imports:
+- name: github.com/pkg/errors
+  version: 645ef00459ed84a119197bfb8d8205042c6df63d
+- name: golang.org/x/net
+  version: f2499483f923065a842d38eb4c7f1927e6fc6e6d
+  repo: https://github.com/golang/net
+  vcs: git
+  subpackages:
+  - context
+  - "http2"
+testImports:
+- name: github.com/stretchr/testify
+  version: 69483b4bd14f5845b5a1e55bca19e954e827f1d0
+  subpackages:
+  - assert
+`,
+		want: []LockedDependency{{
+			Importpath: "github.com/pkg/errors",
+			Root:       "github.com/pkg/errors",
+			Revision:   "645ef00459ed84a119197bfb8d8205042c6df63d",
+		}, {
+			Importpath: "golang.org/x/net/context",
+			Root:       "golang.org/x/net",
+			Repository: "https://github.com/golang/net",
+			VCS:        "git",
+			Revision:   "f2499483f923065a842d38eb4c7f1927e6fc6e6d",
+		}, {
+			Importpath: "golang.org/x/net/http2",
+			Root:       "golang.org/x/net",
+			Repository: "https://github.com/golang/net",
+			VCS:        "git",
+			Revision:   "f2499483f923065a842d38eb4c7f1927e6fc6e6d",
+		}, {
+			Importpath: "github.com/stretchr/testify/assert",
+			Root:       "github.com/stretchr/testify",
+			Revision:   "69483b4bd14f5845b5a1e55bca19e954e827f1d0",
+		}},
+	}, {
+		name: "Gopkg.lock",
+		in: `# This file is autogenerated, do not edit; changes may be undone by the next 'dep ensure'.
+
+
+[[projects]]
+  name = "github.com/pkg/errors"
+  packages = ["."]
+  revision = "645ef00459ed84a119197bfb8d8205042c6df63d"
+  version = "v0.8.0"
+
+[[projects]]
+  branch = "master"
+  name = "golang.org/x/net"
+  packages = [
+    "context",
+    "http2",
+  ]
+  revision = "f2499483f923065a842d38eb4c7f1927e6fc6e6d"
+  source = "https://github.com/golang/net"
+
+[[projects]]
+  name = "gopkg.in/yaml.v2"
+  packages = ["."]
+  revision = "a5b47d31c556af34a302ce5d659e6fea44d90de0"
+  source = "github.com/fork/yaml"
+
+[solve-meta]
+  analyzer-name = "dep"
+  inputs-digest = "0a1b2c"
+`,
+		want: []LockedDependency{{
+			Importpath: "github.com/pkg/errors",
+			Root:       "github.com/pkg/errors",
+			Revision:   "645ef00459ed84a119197bfb8d8205042c6df63d",
+		}, {
+			Importpath: "golang.org/x/net/context",
+			Root:       "golang.org/x/net",
+			Repository: "https://github.com/golang/net",
+			Revision:   "f2499483f923065a842d38eb4c7f1927e6fc6e6d",
+			Branch:     "master",
+		}, {
+			Importpath: "golang.org/x/net/http2",
+			Root:       "golang.org/x/net",
+			Repository: "https://github.com/golang/net",
+			Revision:   "f2499483f923065a842d38eb4c7f1927e6fc6e6d",
+			Branch:     "master",
+		}, {
+			Importpath: "gopkg.in/yaml.v2",
+			Root:       "gopkg.in/yaml.v2",
+			Repository: "github.com/fork/yaml",
+			Revision:   "a5b47d31c556af34a302ce5d659e6fea44d90de0",
+		}},
+	}, {
+		name: "vendor.conf",
+		in: `# runtime
+github.com/pkg/errors v0.8.0
+golang.org/x/net f2499483f923065a842d38eb4c7f1927e6fc6e6d https://github.com/golang/net # fork
+gopkg.in/yaml.v2 a5b47d31c556af34a302ce5d659e6fea44d90de0 github.com/fork/yaml
+`,
+		want: []LockedDependency{{
+			Importpath: "github.com/pkg/errors",
+			Root:       "github.com/pkg/errors",
+			Revision:   "v0.8.0",
+		}, {
+			Importpath: "golang.org/x/net",
+			Root:       "golang.org/x/net",
+			Repository: "https://github.com/golang/net",
+			Revision:   "f2499483f923065a842d38eb4c7f1927e6fc6e6d",
+		}, {
+			Importpath: "gopkg.in/yaml.v2",
+			Root:       "gopkg.in/yaml.v2",
+			Repository: "github.com/fork/yaml",
+			Revision:   "a5b47d31c556af34a302ce5d659e6fea44d90de0",
+		}},
+	}}
+
+	parsers := map[string]func(string) ([]LockedDependency, error){
+		"Godeps.json": func(s string) ([]LockedDependency, error) { return parseGodeps(strings.NewReader(s)) },
+		"glide.lock":  func(s string) ([]LockedDependency, error) { return parseGlideLock(strings.NewReader(s)) },
+		"Gopkg.lock":  func(s string) ([]LockedDependency, error) { return parseGopkgLock(strings.NewReader(s)) },
+		"vendor.conf": func(s string) ([]LockedDependency, error) { return parseVendorConf(strings.NewReader(s)) },
+	}
+
+	for _, tt := range tests {
+		got, err := parsers[tt.name](tt.in)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: want %+v, got %+v", tt.name, tt.want, got)
+		}
+	}
+}
+
+func TestRepositoryPath(t *testing.T) {
+	tests := []struct {
+		repository, want string
+	}{
+		{"", ""},
+		{"https://github.com/fork/yaml", ""},
+		{"git+ssh://git@github.com/fork/yaml", ""},
+		{"/srv/git/yaml", ""},
+		{"github.com/fork/yaml", "github.com/fork/yaml"},
+		{"golang.org/x/net", "golang.org/x/net"},
+	}
+	for _, tt := range tests {
+		l := LockedDependency{Repository: tt.repository}
+		if got := l.RepositoryPath(); got != tt.want {
+			t.Errorf("RepositoryPath of %q: want %q, got %q", tt.repository, tt.want, got)
+		}
+	}
+}
+
+func TestReadLockFile(t *testing.T) {
+	root := mktemp(t)
+	defer fileutils.RemoveAll(root)
+
+	deps, name, err := ReadLockFile(root)
+	if err != nil || name != "" || deps != nil {
+		t.Fatalf("expected no lock file, got %q, %v, %v", name, deps, err)
+	}
+
+	glide := "imports:\n- name: github.com/pkg/errors\n  version: 645ef00459ed84a119197bfb8d8205042c6df63d\n"
+	if err := ioutil.WriteFile(filepath.Join(root, "glide.lock"), []byte(glide), 0644); err != nil {
+		t.Fatal(err)
+	}
+	deps, name, err = ReadLockFile(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name != "glide.lock" || len(deps) != 1 || deps[0].Importpath != "github.com/pkg/errors" {
+		t.Fatalf("expected glide.lock to be read, got %q, %+v", name, deps)
+	}
+
+	// vendor/manifest takes precedence over the other formats
+	m := &Manifest{Dependencies: []Dependency{{
+		Importpath: "github.com/foo/bar/baz",
+		Repository: "https://github.com/foo/bar",
+		Revision:   "1234",
+		Branch:     "master",
+		Path:       "/baz",
+	}}}
+	if err := os.MkdirAll(filepath.Join(root, "vendor"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteManifest(filepath.Join(root, "vendor", "manifest"), m); err != nil {
+		t.Fatal(err)
+	}
+	deps, name, err = ReadLockFile(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []LockedDependency{{
+		Importpath: "github.com/foo/bar/baz",
+		Root:       "github.com/foo/bar",
+		Repository: "https://github.com/foo/bar",
+		Revision:   "1234",
+		Branch:     "master",
+	}}
+	if name != "vendor/manifest" || !reflect.DeepEqual(deps, want) {
+		t.Fatalf("expected vendor/manifest to be read, got %q, %+v", name, deps)
+	}
+}
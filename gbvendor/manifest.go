@@ -6,8 +6,10 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"log"
 	"os"
+	"path/filepath"
 	"reflect"
 	"sort"
 	"strings"
@@ -15,6 +17,10 @@ import (
 
 // gb-vendor manifest support
 
+// ManifestVersion is the current manifest version. Manifests with a higher
+// version are rejected by ReadManifest.
+const ManifestVersion = 0
+
 // Manifest describes the layout of $PROJECT/vendor/manifest.
 type Manifest struct {
 	// Manifest version. Current manifest version is 0.
@@ -22,6 +28,26 @@ type Manifest struct {
 
 	// Depenencies is a list of vendored dependencies.
 	Dependencies []Dependency `json:"dependencies"`
+
+	// Extra holds the unknown top-level fields of the manifest,
+	// which are written back unchanged.
+	Extra map[string]json.RawMessage `json:"-"`
+
+	// Overlapping holds the dependencies that ReadManifest dropped because
+	// a parent of their import path is also vendored. They are not written back.
+	Overlapping []Dependency `json:"-"`
+}
+
+func (m Manifest) MarshalJSON() ([]byte, error) {
+	type manifest Manifest
+	return marshalExtra(manifest(m), m.Extra)
+}
+
+func (m *Manifest) UnmarshalJSON(b []byte) error {
+	type manifest Manifest
+	extra, err := unmarshalExtra(b, (*manifest)(m))
+	m.Extra = extra
+	return err
 }
 
 var (
@@ -99,6 +125,10 @@ type Dependency struct {
 	// VCS is the DVCS system found at Repository.
 	VCS string `json:"vcs"`
 
+	// Mirror is the URL the repository was actually fetched from, if it was
+	// redirected to a mirror by a rewrite rule. Repository remains canonical.
+	Mirror string `json:"mirror,omitempty"`
+
 	// Revision is the revision that describes the dependency's
 	// remote revision.
 	Revision string `json:"revision"`
@@ -117,6 +147,114 @@ type Dependency struct {
 
 	// AllFiles indicates that no files were ignored.
 	AllFiles bool `json:"allfiles,omitempty"`
+
+	// Prune indicates that only the packages imported by the project,
+	// directly or not, were vendored.
+	Prune bool `json:"prune,omitempty"`
+
+	// Flatten indicates that the nested vendor folders were stripped, and
+	// the packages vendored in them were vendored at the top level instead.
+	Flatten bool `json:"flatten,omitempty"`
+
+	// Packages are the vendored packages of a pruned dependency, as paths
+	// relative to Importpath. The package at Importpath itself is ".".
+	Packages []string `json:"packages,omitempty"`
+
+	// Checksum is the hash of the vendored files, as computed by Checksum.
+	// Can be blank in manifests written by older versions.
+	Checksum string `json:"checksum,omitempty"`
+
+	// Constraint is the semantic version constraint the revision was
+	// selected with, if any. Update moves to the highest tag matching it.
+	Constraint string `json:"constraint,omitempty"`
+
+	// Pinned indicates that the dependency must not be updated.
+	Pinned bool `json:"pinned,omitempty"`
+
+	// PinReason optionally explains why the dependency is pinned.
+	PinReason string `json:"pinreason,omitempty"`
+
+	// Extra holds the unknown fields of the dependency entry,
+	// which are written back unchanged.
+	Extra map[string]json.RawMessage `json:"-"`
+}
+
+func (d Dependency) MarshalJSON() ([]byte, error) {
+	type dependency Dependency
+	return marshalExtra(dependency(d), d.Extra)
+}
+
+func (d *Dependency) UnmarshalJSON(b []byte) error {
+	type dependency Dependency
+	extra, err := unmarshalExtra(b, (*dependency)(d))
+	d.Extra = extra
+	return err
+}
+
+// marshalExtra encodes the struct v as a JSON object, followed by the fields
+// in extra, sorted by name.
+func marshalExtra(v interface{}, extra map[string]json.RawMessage) ([]byte, error) {
+	b, err := json.Marshal(v)
+	if err != nil || len(extra) == 0 {
+		return b, err
+	}
+
+	var names []string
+	for name := range extra {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var buf bytes.Buffer
+	buf.Write(b[:len(b)-1])
+	for _, name := range names {
+		if buf.Len() > 1 {
+			buf.WriteByte(',')
+		}
+		k, err := json.Marshal(name)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(k)
+		buf.WriteByte(':')
+		buf.Write(extra[name])
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// unmarshalExtra decodes the JSON object b into v, a pointer to a struct,
+// and returns the fields of b that don't match any field of v.
+func unmarshalExtra(b []byte, v interface{}) (map[string]json.RawMessage, error) {
+	if err := json.Unmarshal(b, v); err != nil {
+		return nil, err
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(b, &fields); err != nil {
+		return nil, err
+	}
+
+	t := reflect.TypeOf(v).Elem()
+	for i := 0; i < t.NumField(); i++ {
+		tag := strings.Split(t.Field(i).Tag.Get("json"), ",")[0]
+		if tag == "-" {
+			continue
+		}
+		if tag == "" {
+			tag = t.Field(i).Name
+		}
+		// encoding/json matches field names case-insensitively
+		for name := range fields {
+			if strings.EqualFold(name, tag) {
+				delete(fields, name)
+			}
+		}
+	}
+
+	if len(fields) == 0 {
+		return nil, nil
+	}
+	return fields, nil
 }
 
 // WriteManifest writes a Manifest to the path. If the manifest does
@@ -124,8 +262,8 @@ type Dependency struct {
 // If the manifest file is empty (0 dependencies) it will be deleted.
 // The dependencies will be ordered by import path to reduce churn when making
 // changes.
-// TODO(dfc) write to temporary file and move atomically to avoid
-// destroying a working vendorfile.
+// The manifest is written to a temporary file which is then moved atomically
+// into place, so an interrupted write never destroys a working manifest.
 func WriteManifest(path string, m *Manifest) error {
 	if len(m.Dependencies) == 0 {
 		err := os.Remove(path)
@@ -134,16 +272,34 @@ func WriteManifest(path string, m *Manifest) error {
 		}
 		return nil
 	}
+	return ReplaceManifest(path, m)
+}
 
-	f, err := os.Create(path)
+// ReplaceManifest writes a Manifest to the path like WriteManifest, but
+// never deletes the file, even if the manifest has no dependencies.
+func ReplaceManifest(path string, m *Manifest) error {
+	f, err := ioutil.TempFile(filepath.Dir(path), ".manifest-")
 	if err != nil {
 		return err
 	}
 	if err := writeManifest(f, m); err != nil {
 		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	if err := os.Chmod(f.Name(), 0644); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	if err := os.Rename(f.Name(), path); err != nil {
+		os.Remove(f.Name())
 		return err
 	}
-	return f.Close()
+	return nil
 }
 
 func writeManifest(w io.Writer, m *Manifest) error {
@@ -168,11 +324,20 @@ func ReadManifest(path string) (*Manifest, error) {
 	}
 	defer f.Close()
 
+	return ParseManifest(f)
+}
+
+// ParseManifest decodes a Manifest from r.
+func ParseManifest(r io.Reader) (*Manifest, error) {
 	var m Manifest
-	d := json.NewDecoder(f)
+	d := json.NewDecoder(r)
 	if err := d.Decode(&m); err != nil {
 		return nil, err
 	}
+	if m.Version > ManifestVersion {
+		return nil, fmt.Errorf("manifest version %d is not supported by this version of gvt (max %d): please upgrade gvt",
+			m.Version, ManifestVersion)
+	}
 
 	// Pass all dependencies through AddDependency to detect overlap
 	deps := m.Dependencies
@@ -182,12 +347,13 @@ func ReadManifest(path string) (*Manifest, error) {
 		if err := m.AddDependency(d); err == DepPresent {
 			log.Println("WARNING: overlapping dependency detected:", d.Importpath)
 			log.Println("The subpackage will be ignored to fix undefined behavior. See https://git.io/vr8Mu")
+			m.Overlapping = append(m.Overlapping, d)
 		} else if err != nil {
 			return nil, err
 		}
 	}
 
-	return &m, err
+	return &m, nil
 }
 
 type byImportpath []Dependency
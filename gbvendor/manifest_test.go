@@ -2,8 +2,10 @@ package vendor
 
 import (
 	"bytes"
+	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/FiloSottile/gvt/fileutils"
@@ -17,6 +19,18 @@ func mktemp(t *testing.T) string {
 	return s
 }
 
+// writeFile writes content to the file at the slash separated path name
+// in the folder root, creating the parent folders.
+func writeFile(t *testing.T, root, name, content string) {
+	path := filepath.Join(root, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
 func assertNotExists(t *testing.T, path string) {
 	_, err := os.Stat(path)
 	if err == nil || !os.IsNotExist(err) {
@@ -64,6 +78,15 @@ func TestManifest(t *testing.T) {
 	// check the manifest was written
 	assertExists(t, mf)
 
+	// check that no temporary file was left behind
+	files, err := ioutil.ReadDir(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 {
+		t.Fatalf("expected only the manifest in %s, found %d files", root, len(files))
+	}
+
 	// remove it
 	m.Dependencies = nil
 	if err := WriteManifest(mf, m); err != nil {
@@ -74,6 +97,25 @@ func TestManifest(t *testing.T) {
 	assertNotExists(t, mf)
 }
 
+func TestReplaceManifestKeepsEmpty(t *testing.T) {
+	root := mktemp(t)
+	defer fileutils.RemoveAll(root)
+
+	mf := filepath.Join(root, "manifest")
+	if err := ReplaceManifest(mf, new(Manifest)); err != nil {
+		t.Fatalf("ReplaceManifest failed: %v", err)
+	}
+	assertExists(t, mf)
+
+	m, err := ReadManifest(mf)
+	if err != nil {
+		t.Fatalf("ReadManifest failed: %v", err)
+	}
+	if len(m.Dependencies) != 0 {
+		t.Fatalf("expected no dependencies, got %+v", m.Dependencies)
+	}
+}
+
 func TestEmptyPathIsNotWritten(t *testing.T) {
 	m := Manifest{
 		Version: 0,
@@ -106,3 +148,100 @@ func TestEmptyPathIsNotWritten(t *testing.T) {
 		t.Fatalf("want: %s, got %s", want, got)
 	}
 }
+
+func TestUnknownFieldsRoundTrip(t *testing.T) {
+	root := mktemp(t)
+	defer fileutils.RemoveAll(root)
+
+	mf := filepath.Join(root, "manifest")
+	in := `{
+	"generator": "github.com/FiloSottile/gvt",
+	"dependencies": [
+		{
+			"importpath": "github.com/foo/bar",
+			"repository": "https://github.com/foo/bar",
+			"vcs": "git",
+			"revision": "abcdef",
+			"branch": "master",
+			"reviewed-by": ["alice", "bob"]
+		}
+	]
+}`
+	if err := ioutil.WriteFile(mf, []byte(in), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := ReadManifest(mf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := writeManifest(&buf, m); err != nil {
+		t.Fatal(err)
+	}
+	want := `{
+	"version": 0,
+	"dependencies": [
+		{
+			"importpath": "github.com/foo/bar",
+			"repository": "https://github.com/foo/bar",
+			"vcs": "git",
+			"revision": "abcdef",
+			"branch": "master",
+			"reviewed-by": [
+				"alice",
+				"bob"
+			]
+		}
+	],
+	"generator": "github.com/FiloSottile/gvt"
+}`
+	got := buf.String()
+	if want != got {
+		t.Fatalf("want: %s, got %s", want, got)
+	}
+}
+
+func TestNewerManifestVersion(t *testing.T) {
+	root := mktemp(t)
+	defer fileutils.RemoveAll(root)
+
+	mf := filepath.Join(root, "manifest")
+	in := `{"version": 1, "dependencies": []}`
+	if err := ioutil.WriteFile(mf, []byte(in), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := ReadManifest(mf); err == nil {
+		t.Fatal("expected an error reading a manifest with a newer version")
+	}
+}
+
+func TestOverlappingDependencies(t *testing.T) {
+	in := `{
+	"version": 0,
+	"dependencies": [
+		{"importpath": "github.com/foo/bar/baz", "repository": "https://github.com/foo/bar", "revision": "1", "path": "/baz"},
+		{"importpath": "github.com/foo/bar", "repository": "https://github.com/foo/bar", "revision": "1"},
+		{"importpath": "github.com/foo/barbaz", "repository": "https://github.com/foo/barbaz", "revision": "1"}
+	]
+}`
+	m, err := ParseManifest(strings.NewReader(in))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m.Dependencies) != 2 {
+		t.Fatalf("expected 2 dependencies, got %v", m.Dependencies)
+	}
+	if len(m.Overlapping) != 1 || m.Overlapping[0].Importpath != "github.com/foo/bar/baz" {
+		t.Fatalf("expected github.com/foo/bar/baz to overlap, got %v", m.Overlapping)
+	}
+
+	var buf bytes.Buffer
+	if err := writeManifest(&buf, m); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(buf.String(), "github.com/foo/bar/baz") {
+		t.Fatalf("overlapping dependency was written back: %s", buf.String())
+	}
+}
@@ -0,0 +1,129 @@
+package vendor
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"sort"
+)
+
+// MergeConflict is a dependency that could not be merged automatically.
+type MergeConflict struct {
+	Importpath string
+
+	// Base, Ours and Theirs are nil where the dependency is not present.
+	Base, Ours, Theirs *Dependency
+
+	// Reason explains the conflict.
+	Reason string
+}
+
+// MergeManifests does a three-way merge of the dependencies of ours and
+// theirs, by import path, using base as their common ancestor.
+//
+// A dependency changed only on one side takes that change. If both sides
+// changed the same dependency differently a MergeConflict is reported, and
+// the result keeps the version from ours. If the merge would produce
+// overlapping dependencies, the subpackage is reported and left out.
+// The other manifest fields are merged the same way, but prefer ours when
+// both sides changed them.
+func MergeManifests(base, ours, theirs *Manifest) (*Manifest, []MergeConflict) {
+	type sides struct{ base, ours, theirs *Dependency }
+	all := make(map[string]*sides)
+	get := func(path string) *sides {
+		if all[path] == nil {
+			all[path] = &sides{}
+		}
+		return all[path]
+	}
+	for i, d := range base.Dependencies {
+		get(d.Importpath).base = &base.Dependencies[i]
+	}
+	for i, d := range ours.Dependencies {
+		get(d.Importpath).ours = &ours.Dependencies[i]
+	}
+	for i, d := range theirs.Dependencies {
+		get(d.Importpath).theirs = &theirs.Dependencies[i]
+	}
+
+	var paths []string
+	for p := range all {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths) // so that parents come before their subpackages
+
+	res := &Manifest{
+		Version: ours.Version,
+		Extra:   mergeExtra(base.Extra, ours.Extra, theirs.Extra),
+	}
+	if ours.Version == base.Version {
+		res.Version = theirs.Version
+	}
+
+	var conflicts []MergeConflict
+	for _, p := range paths {
+		s := all[p]
+		d, ok := merge3(s.base, s.ours, s.theirs)
+		if !ok {
+			conflicts = append(conflicts, MergeConflict{
+				Importpath: p, Base: s.base, Ours: s.ours, Theirs: s.theirs,
+				Reason: "changed on both sides",
+			})
+			d = s.ours
+		}
+		if d == nil {
+			continue
+		}
+		if parent, err := res.GetDependencyForImportpath(p); err == nil {
+			conflicts = append(conflicts, MergeConflict{
+				Importpath: p, Base: s.base, Ours: s.ours, Theirs: s.theirs,
+				Reason: "overlaps with " + parent.Importpath,
+			})
+			continue
+		}
+		res.Dependencies = append(res.Dependencies, *d)
+	}
+
+	return res, conflicts
+}
+
+// merge3 returns the merged version of a dependency, or false if both
+// sides changed it differently.
+func merge3(base, ours, theirs *Dependency) (*Dependency, bool) {
+	switch {
+	case reflect.DeepEqual(ours, theirs):
+		return ours, true
+	case reflect.DeepEqual(base, ours):
+		return theirs, true
+	case reflect.DeepEqual(base, theirs):
+		return ours, true
+	}
+	return nil, false
+}
+
+// mergeExtra merges unknown top-level fields, preferring ours unless
+// only theirs changed a field.
+func mergeExtra(base, ours, theirs map[string]json.RawMessage) map[string]json.RawMessage {
+	keys := make(map[string]bool)
+	for _, m := range []map[string]json.RawMessage{base, ours, theirs} {
+		for k := range m {
+			keys[k] = true
+		}
+	}
+
+	res := make(map[string]json.RawMessage)
+	for k := range keys {
+		b, inBase := base[k]
+		v, ok := ours[k]
+		if ok == inBase && bytes.Equal(v, b) {
+			v, ok = theirs[k]
+		}
+		if ok {
+			res[k] = v
+		}
+	}
+	if len(res) == 0 {
+		return nil
+	}
+	return res
+}
@@ -0,0 +1,75 @@
+package vendor
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestMergeManifests(t *testing.T) {
+	dep := func(path, rev string) Dependency {
+		return Dependency{
+			Importpath: path,
+			Repository: "https://" + path,
+			VCS:        "git",
+			Revision:   rev,
+			Branch:     "master",
+		}
+	}
+
+	base := &Manifest{
+		Dependencies: []Dependency{
+			dep("github.com/a/a", "1"),
+			dep("github.com/b/b", "1"),
+			dep("github.com/c/c", "1"),
+			dep("github.com/d/d", "1"),
+		},
+		Extra: map[string]json.RawMessage{"generator": json.RawMessage(`"gvt"`)},
+	}
+	ours := &Manifest{
+		Dependencies: []Dependency{
+			dep("github.com/a/a", "2"), // updated by us
+			dep("github.com/b/b", "1"),
+			dep("github.com/c/c", "2"), // conflict
+			dep("github.com/d/d", "1"),
+			dep("github.com/e/e", "1"), // added by us
+		},
+		Extra: map[string]json.RawMessage{"generator": json.RawMessage(`"gvt"`)},
+	}
+	theirs := &Manifest{
+		Dependencies: []Dependency{
+			dep("github.com/a/a", "1"),
+			dep("github.com/b/b", "2"), // updated by them
+			dep("github.com/c/c", "3"), // conflict
+			// github.com/d/d removed by them
+			dep("github.com/e/e/sub", "1"), // overlaps with ours
+			dep("github.com/f/f", "1"),     // added by them
+		},
+		Extra: map[string]json.RawMessage{"generator": json.RawMessage(`"other"`)},
+	}
+
+	got, conflicts := MergeManifests(base, ours, theirs)
+
+	want := []Dependency{
+		dep("github.com/a/a", "2"),
+		dep("github.com/b/b", "2"),
+		dep("github.com/c/c", "2"),
+		dep("github.com/e/e", "1"),
+		dep("github.com/f/f", "1"),
+	}
+	if !reflect.DeepEqual(got.Dependencies, want) {
+		t.Errorf("MergeManifests: want %+v, got %+v", want, got.Dependencies)
+	}
+	if g := string(got.Extra["generator"]); g != `"other"` {
+		t.Errorf("MergeManifests: want generator %q, got %q", `"other"`, g)
+	}
+
+	var paths []string
+	for _, c := range conflicts {
+		paths = append(paths, c.Importpath)
+	}
+	wantConflicts := []string{"github.com/c/c", "github.com/e/e/sub"}
+	if !reflect.DeepEqual(paths, wantConflicts) {
+		t.Errorf("MergeManifests: want conflicts %v, got %v", wantConflicts, paths)
+	}
+}
@@ -0,0 +1,102 @@
+package vendor
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	majorSuffix   = regexp.MustCompile(`/v([2-9]|[1-9][0-9]+)$`)
+	gopkginSuffix = regexp.MustCompile(`^gopkg\.in/.*\.v([0-9]+)(-unstable)?$`)
+)
+
+// PseudoVersion returns the Go module pseudo-version for revision rev of
+// the module at modPath, committed at t. The major version is derived from
+// the /vN or gopkg.in .vN suffix of modPath.
+func PseudoVersion(modPath string, t time.Time, rev string) (string, error) {
+	major, _ := pathMajor(modPath)
+	return pseudoVersion(fmt.Sprintf("v%d.0.0-", major), t, rev)
+}
+
+// ModuleVersion returns the go.mod version for revision rev of the module
+// at modPath, committed at t. head are the tags of rev, and merged those of
+// rev and of its ancestors. If rev has a semver tag matching the major
+// version of modPath, that is the version; otherwise a pseudo-version is
+// derived from the highest matching tag in merged, if any.
+//
+// A repository without a go.mod may be tagged v2 or higher while its
+// module path has no major version suffix: such versions get the
+// +incompatible suffix.
+func ModuleVersion(modPath string, t time.Time, rev string, head, merged []string, hasGoMod bool) (string, error) {
+	major, suffixed := pathMajor(modPath)
+	match := func(v semver) bool {
+		if suffixed {
+			return v.major == major
+		}
+		return v.major <= 1 || !hasGoMod
+	}
+	incompatible := func(v semver) string {
+		if !suffixed && v.major >= 2 {
+			return "+incompatible"
+		}
+		return ""
+	}
+
+	if tag, v, ok := highestTag(head, match); ok {
+		return tag + incompatible(v), nil
+	}
+	tag, v, ok := highestTag(merged, match)
+	if !ok {
+		return PseudoVersion(modPath, t, rev)
+	}
+	var base string
+	if v.pre != "" {
+		base = tag + ".0."
+	} else {
+		base = fmt.Sprintf("v%d.%d.%d-0.", v.major, v.minor, v.patch+1)
+	}
+	pv, err := pseudoVersion(base, t, rev)
+	return pv + incompatible(v), err
+}
+
+func pseudoVersion(base string, t time.Time, rev string) (string, error) {
+	if len(rev) < 12 {
+		return "", fmt.Errorf("revision %q is too short for a pseudo-version", rev)
+	}
+	return fmt.Sprintf("%s%s-%s", base, t.UTC().Format("20060102150405"), rev[:12]), nil
+}
+
+// pathMajor returns the major version from the /vN or gopkg.in .vN suffix
+// of modPath, and whether there is one.
+func pathMajor(modPath string) (int, bool) {
+	m := majorSuffix.FindStringSubmatch(modPath)
+	if m == nil {
+		m = gopkginSuffix.FindStringSubmatch(modPath)
+	}
+	if m == nil {
+		return 0, false
+	}
+	major, _ := strconv.Atoi(m[1])
+	return major, true
+}
+
+// highestTag returns the highest of tags that is a complete semantic version
+// and for which match returns true.
+func highestTag(tags []string, match func(semver) bool) (tag string, v semver, ok bool) {
+	for _, t := range tags {
+		if !strings.HasPrefix(t, "v") || strings.Contains(t, "+") {
+			continue
+		}
+		sv, n, err := parseSemver(t, false)
+		if err != nil || n < 3 || !match(sv) {
+			continue
+		}
+		if !ok || sv.compare(v) > 0 {
+			tag, v, ok = t, sv, true
+		}
+	}
+	return tag, v, ok
+}
@@ -0,0 +1,68 @@
+package vendor
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPseudoVersion(t *testing.T) {
+	commit := time.Date(2017, 1, 20, 16, 4, 5, 0, time.FixedZone("PST", -8*3600))
+	rev := "8b84dae17391c154ca50b0162662aa1fc9ff84c2"
+	tests := []struct {
+		path, want string
+	}{
+		{"golang.org/x/tools", "v0.0.0-20170121000405-8b84dae17391"},
+		{"github.com/foo/bar/v2", "v2.0.0-20170121000405-8b84dae17391"},
+		{"github.com/foo/bar/v10", "v10.0.0-20170121000405-8b84dae17391"},
+		{"github.com/foo/v1", "v0.0.0-20170121000405-8b84dae17391"},
+		{"gopkg.in/yaml.v2", "v2.0.0-20170121000405-8b84dae17391"},
+		{"gopkg.in/check.v1", "v1.0.0-20170121000405-8b84dae17391"},
+	}
+	for _, tt := range tests {
+		got, err := PseudoVersion(tt.path, commit, rev)
+		if err != nil {
+			t.Errorf("PseudoVersion(%q): %v", tt.path, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("PseudoVersion(%q): want %s, got %s", tt.path, tt.want, got)
+		}
+	}
+
+	if _, err := PseudoVersion("launchpad.net/gnuflag", commit, "1"); err == nil {
+		t.Errorf("PseudoVersion with a bzr revision: expected an error")
+	}
+}
+
+func TestModuleVersion(t *testing.T) {
+	commit := time.Date(2017, 1, 20, 16, 4, 5, 0, time.FixedZone("PST", -8*3600))
+	rev := "8b84dae17391c154ca50b0162662aa1fc9ff84c2"
+	tests := []struct {
+		path         string
+		head, merged []string
+		hasGoMod     bool
+		want         string
+	}{
+		{"github.com/foo/bar", nil, nil, false, "v0.0.0-20170121000405-8b84dae17391"},
+		{"github.com/foo/bar", []string{"v1.2.3"}, []string{"v1.2.3", "v1.0.0"}, true, "v1.2.3"},
+		{"github.com/foo/bar", nil, []string{"v1.2.3", "v1.10.0", "latest"}, true, "v1.10.1-0.20170121000405-8b84dae17391"},
+		{"github.com/foo/bar", nil, []string{"v1.3.0-rc.1"}, true, "v1.3.0-rc.1.0.20170121000405-8b84dae17391"},
+		{"github.com/foo/bar", []string{"v2.1.0"}, []string{"v2.1.0", "v1.0.0"}, false, "v2.1.0+incompatible"},
+		{"github.com/foo/bar", nil, []string{"v2.1.0", "v1.0.0"}, false, "v2.1.1-0.20170121000405-8b84dae17391+incompatible"},
+		{"github.com/foo/bar", []string{"v2.1.0"}, []string{"v2.1.0", "v1.0.0"}, true, "v1.0.1-0.20170121000405-8b84dae17391"},
+		{"github.com/foo/bar/v2", []string{"v2.1.0"}, []string{"v2.1.0", "v1.0.0"}, true, "v2.1.0"},
+		{"github.com/foo/bar/v2", nil, []string{"v1.0.0"}, true, "v2.0.0-20170121000405-8b84dae17391"},
+		{"gopkg.in/yaml.v2", nil, []string{"v2.0.0", "v3.0.0"}, false, "v2.0.1-0.20170121000405-8b84dae17391"},
+		{"github.com/foo/bar", []string{"v1.2", "v1.2.3+meta"}, nil, false, "v0.0.0-20170121000405-8b84dae17391"},
+	}
+	for _, tt := range tests {
+		got, err := ModuleVersion(tt.path, commit, rev, tt.head, tt.merged, tt.hasGoMod)
+		if err != nil {
+			t.Errorf("ModuleVersion(%q, %v, %v): %v", tt.path, tt.head, tt.merged, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ModuleVersion(%q, %v, %v): want %s, got %s", tt.path, tt.head, tt.merged, tt.want, got)
+		}
+	}
+}
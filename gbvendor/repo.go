@@ -2,6 +2,7 @@ package vendor
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -11,7 +12,9 @@ import (
 	"os/exec"
 	"path/filepath"
 	"regexp"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/FiloSottile/gvt/fileutils"
 )
@@ -22,13 +25,16 @@ type RemoteRepo interface {
 	// Checkout checks out a specific branch, tag, or revision.
 	// The interpretation of these three values is impementation
 	// specific.
-	Checkout(branch, tag, revision string) (WorkingCopy, error)
+	Checkout(ctx context.Context, branch, tag, revision string) (WorkingCopy, error)
 
 	// URL returns the URL the clone was/will be taken from.
 	URL() string
 
 	// Type returns the repository type (git, hg, ...)
 	Type() string
+
+	// Tags returns the names of the tags of the remote repository.
+	Tags(ctx context.Context) ([]string, error)
 }
 
 // WorkingCopy represents a local copy of a remote dvcs repository.
@@ -38,10 +44,21 @@ type WorkingCopy interface {
 	Dir() string
 
 	// Revision returns the revision of this working copy.
-	Revision() (string, error)
+	Revision(ctx context.Context) (string, error)
 
 	// Branch returns the branch to which this working copy belongs.
-	Branch() (string, error)
+	Branch(ctx context.Context) (string, error)
+
+	// CommitTime returns the time of the checked out revision.
+	CommitTime(ctx context.Context) (time.Time, error)
+
+	// Tags returns the tags of the checked out revision, and those of
+	// the checked out revision and of its ancestors.
+	Tags(ctx context.Context) (head, merged []string, err error)
+
+	// Log returns the subjects of the commits that are ancestors of
+	// revision to but not of revision from, newest first.
+	Log(ctx context.Context, from, to string) ([]string, error)
 
 	// Destroy removes the working copy.
 	Destroy() error
@@ -53,6 +70,7 @@ var (
 	lpregex   = regexp.MustCompile(`^launchpad.net/([A-Za-z0-9-._]+)(/[A-Za-z0-9-._]+)?(/.+)?`)
 	gcregex   = regexp.MustCompile(`^(?P<root>code\.google\.com/[pr]/(?P<project>[a-z0-9\-]+)(\.(?P<subrepo>[a-z0-9\-]+))?)(/[A-Za-z0-9_.\-]+)*$`)
 	genericre = regexp.MustCompile(`^(?P<root>(?P<repo>([a-z0-9.\-]+\.)+[a-z0-9.\-]+(:[0-9]+)?/[A-Za-z0-9_.\-/~]*?)\.(?P<vcs>bzr|git|hg|svn))([/A-Za-z0-9_.\-~]+)*$`)
+	importre  = regexp.MustCompile(`^([A-Za-z0-9-]+)(\.[A-Za-z0-9-]+)+(/[A-Za-z0-9-_.~]+)*$`)
 )
 
 // DeduceRemoteRepo takes a potential import path and returns a RemoteRepo
@@ -60,7 +78,9 @@ var (
 // Remote repositories can be bare import paths, or urls including a checkout scheme.
 // If deduction would cause traversal of an insecure host, a message will be
 // printed and the travelsal path will be ignored.
-func DeduceRemoteRepo(path string, insecure bool) (RemoteRepo, string, error) {
+// If a rule in Rewrites matches path, the repository is looked up in the
+// mirror it points to instead.
+func DeduceRemoteRepo(ctx context.Context, path string, insecure bool) (RemoteRepo, string, error) {
 	u, err := url.Parse(path)
 	if err != nil {
 		return nil, "", fmt.Errorf("%q is not a valid import path", path)
@@ -72,10 +92,14 @@ func DeduceRemoteRepo(path string, insecure bool) (RemoteRepo, string, error) {
 	}
 
 	path = u.Host + u.Path
-	if !regexp.MustCompile(`^([A-Za-z0-9-]+)(\.[A-Za-z0-9-]+)+(/[A-Za-z0-9-_.~]+)*$`).MatchString(path) {
+	if !IsRemoteImportPath(path) {
 		return nil, "", fmt.Errorf("%q is not a valid import path", path)
 	}
 
+	if repo, extra, ok, err := deduceMirrorRepo(ctx, path, insecure); ok {
+		return repo, extra, err
+	}
+
 	switch {
 	case ghregex.MatchString(path):
 		v := ghregex.FindStringSubmatch(path)
@@ -83,7 +107,7 @@ func DeduceRemoteRepo(path string, insecure bool) (RemoteRepo, string, error) {
 			Host: "github.com",
 			Path: v[2],
 		}
-		repo, err := Gitrepo(url, insecure, schemes...)
+		repo, err := Gitrepo(ctx, url, insecure, schemes...)
 		return repo, v[0][len(v[1]):], err
 	case bbregex.MatchString(path):
 		v := bbregex.FindStringSubmatch(path)
@@ -91,11 +115,11 @@ func DeduceRemoteRepo(path string, insecure bool) (RemoteRepo, string, error) {
 			Host: "bitbucket.org",
 			Path: v[2],
 		}
-		repo, err := Gitrepo(url, insecure, schemes...)
+		repo, err := Gitrepo(ctx, url, insecure, schemes...)
 		if err == nil {
 			return repo, v[0][len(v[1]):], nil
 		}
-		repo, err = Hgrepo(url, insecure)
+		repo, err = Hgrepo(ctx, url, insecure)
 		if err == nil {
 			return repo, v[0][len(v[1]):], nil
 		}
@@ -106,11 +130,11 @@ func DeduceRemoteRepo(path string, insecure bool) (RemoteRepo, string, error) {
 			Host: "code.google.com",
 			Path: "p/" + v[2],
 		}
-		repo, err := Hgrepo(url, insecure, schemes...)
+		repo, err := Hgrepo(ctx, url, insecure, schemes...)
 		if err == nil {
 			return repo, v[0][len(v[1]):], nil
 		}
-		repo, err = Gitrepo(url, insecure, schemes...)
+		repo, err = Gitrepo(ctx, url, insecure, schemes...)
 		if err == nil {
 			return repo, v[0][len(v[1]):], nil
 		}
@@ -120,11 +144,11 @@ func DeduceRemoteRepo(path string, insecure bool) (RemoteRepo, string, error) {
 		v = append(v, "", "")
 		if v[2] == "" {
 			// launchpad.net/project"
-			repo, err := Bzrrepo(fmt.Sprintf("https://launchpad.net/%v", v[1]))
+			repo, err := Bzrrepo(ctx, fmt.Sprintf("https://launchpad.net/%v", v[1]))
 			return repo, "", err
 		}
 		// launchpad.net/project/series"
-		repo, err := Bzrrepo(fmt.Sprintf("https://launchpad.net/%s/%s", v[1], v[2]))
+		repo, err := Bzrrepo(ctx, fmt.Sprintf("https://launchpad.net/%s/%s", v[1], v[2]))
 		return repo, v[3], err
 	}
 
@@ -138,7 +162,7 @@ func DeduceRemoteRepo(path string, insecure bool) (RemoteRepo, string, error) {
 				Host: x[0],
 				Path: x[1],
 			}
-			repo, err := Gitrepo(url, insecure, schemes...)
+			repo, err := Gitrepo(ctx, url, insecure, schemes...)
 			return repo, v[6], err
 		case "hg":
 			x := strings.SplitN(v[1], "/", 2)
@@ -146,10 +170,10 @@ func DeduceRemoteRepo(path string, insecure bool) (RemoteRepo, string, error) {
 				Host: x[0],
 				Path: x[1],
 			}
-			repo, err := Hgrepo(url, insecure, schemes...)
+			repo, err := Hgrepo(ctx, url, insecure, schemes...)
 			return repo, v[6], err
 		case "bzr":
-			repo, err := Bzrrepo("https://" + v[1])
+			repo, err := Bzrrepo(ctx, "https://"+v[1])
 			return repo, v[6], err
 		default:
 			return nil, "", fmt.Errorf("unknown repository type: %q", v[5])
@@ -158,7 +182,7 @@ func DeduceRemoteRepo(path string, insecure bool) (RemoteRepo, string, error) {
 	}
 
 	// no idea, try to resolve as a vanity import
-	importpath, vcs, reporoot, err := ParseMetadata(path, insecure)
+	importpath, vcs, reporoot, err := ParseMetadata(ctx, path, insecure)
 	if err != nil {
 		return nil, "", err
 	}
@@ -170,41 +194,59 @@ func DeduceRemoteRepo(path string, insecure bool) (RemoteRepo, string, error) {
 	switch vcs {
 	case "git":
 		u.Path = u.Path[1:]
-		repo, err := Gitrepo(u, insecure, u.Scheme)
+		repo, err := Gitrepo(ctx, u, insecure, u.Scheme)
 		return repo, extra, err
 	case "hg":
 		u.Path = u.Path[1:]
-		repo, err := Hgrepo(u, insecure, u.Scheme)
+		repo, err := Hgrepo(ctx, u, insecure, u.Scheme)
 		return repo, extra, err
 	case "bzr":
-		repo, err := Bzrrepo(reporoot)
+		repo, err := Bzrrepo(ctx, reporoot)
 		return repo, extra, err
 	default:
 		return nil, "", fmt.Errorf("unknown repository type: %q", vcs)
 	}
 }
 
-func NewRemoteRepo(repoURL, vcs string, insecure bool) (RemoteRepo, error) {
+// NewRemoteRepo returns a RemoteRepo for the repository at repoURL of type
+// vcs, or of any type if vcs is empty. repoURL can also be a file:// URL or
+// an absolute local path, which is used as is.
+// If a rule in Rewrites matches repoURL, the repository is fetched from
+// the mirror it points to.
+func NewRemoteRepo(ctx context.Context, repoURL, vcs string, insecure bool) (RemoteRepo, error) {
+	if u, err := url.Parse(repoURL); err == nil && !filepath.IsAbs(repoURL) {
+		if mirror := Rewrites.Rewrite(u.Host + u.Path); mirror != "" {
+			return NewMirrorRepo(ctx, repoURL, mirror, vcs, insecure)
+		}
+	}
+	return newRemoteRepo(ctx, repoURL, vcs, insecure)
+}
+
+func newRemoteRepo(ctx context.Context, repoURL, vcs string, insecure bool) (RemoteRepo, error) {
+	if filepath.IsAbs(repoURL) {
+		return localRepo(ctx, repoURL, vcs)
+	}
+
 	u, err := url.Parse(repoURL)
 	if err != nil {
 		return nil, fmt.Errorf("%q is not a valid import path", repoURL)
 	}
 	switch vcs {
 	case "git":
-		return Gitrepo(u, insecure, u.Scheme)
+		return Gitrepo(ctx, u, insecure, u.Scheme)
 	case "hg":
-		return Hgrepo(u, insecure, u.Scheme)
+		return Hgrepo(ctx, u, insecure, u.Scheme)
 	case "bzr":
-		return Bzrrepo(repoURL)
+		return Bzrrepo(ctx, repoURL)
 	case "":
 		// for backwards compatibility with manifests that miss the VCS entry
-		if repo, err := Gitrepo(u, insecure, u.Scheme); err == nil {
+		if repo, err := Gitrepo(ctx, u, insecure, u.Scheme); err == nil {
 			return repo, nil
 		}
-		if repo, err := Hgrepo(u, insecure, u.Scheme); err == nil {
+		if repo, err := Hgrepo(ctx, u, insecure, u.Scheme); err == nil {
 			return repo, nil
 		}
-		if repo, err := Bzrrepo(repoURL); err == nil {
+		if repo, err := Bzrrepo(ctx, repoURL); err == nil {
 			return repo, nil
 		}
 		return nil, fmt.Errorf("can't reach %q", repoURL)
@@ -212,12 +254,41 @@ func NewRemoteRepo(repoURL, vcs string, insecure bool) (RemoteRepo, error) {
 	return nil, fmt.Errorf("%q is not a valid VCS", vcs)
 }
 
+// localRepo returns a RemoteRepo for the repository at the local path.
+func localRepo(ctx context.Context, path, vcs string) (RemoteRepo, error) {
+	switch vcs {
+	case "git":
+		if err := isGitRepo(ctx, path); err != nil {
+			return nil, fmt.Errorf("%s is not a git repository: %v", path, err)
+		}
+		return &gitrepo{url: path}, nil
+	case "hg":
+		if err := isHgRepo(ctx, path); err != nil {
+			return nil, fmt.Errorf("%s is not a hg repository: %v", path, err)
+		}
+		return &hgrepo{url: path}, nil
+	case "bzr":
+		if err := isBzrRepo(ctx, path); err != nil {
+			return nil, fmt.Errorf("%s is not a bzr repository: %v", path, err)
+		}
+		return &bzrrepo{url: path}, nil
+	case "":
+		for _, vcs := range []string{"git", "hg", "bzr"} {
+			if repo, err := localRepo(ctx, path, vcs); err == nil {
+				return repo, nil
+			}
+		}
+		return nil, fmt.Errorf("%s is not a git, hg or bzr repository", path)
+	}
+	return nil, fmt.Errorf("%q is not a valid VCS", vcs)
+}
+
 // Gitrepo returns a RemoteRepo representing a remote git repository.
-func Gitrepo(url *url.URL, insecure bool, schemes ...string) (RemoteRepo, error) {
+func Gitrepo(ctx context.Context, url *url.URL, insecure bool, schemes ...string) (RemoteRepo, error) {
 	if len(schemes) == 0 {
 		schemes = []string{"https", "git", "ssh", "http"}
 	}
-	u, err := probeGitUrl(url, insecure, schemes)
+	u, err := probeGitUrl(ctx, url, insecure, schemes)
 	if err != nil {
 		return nil, err
 	}
@@ -226,39 +297,71 @@ func Gitrepo(url *url.URL, insecure bool, schemes ...string) (RemoteRepo, error)
 	}, nil
 }
 
-func probeGitUrl(u *url.URL, insecure bool, schemes []string) (string, error) {
-	git := func(url *url.URL) error {
-		out, err := run("git", "ls-remote", url.String(), "HEAD")
-		if err != nil {
-			return err
-		}
+func isGitRepo(ctx context.Context, url string) error {
+	out, err := runProbe(ctx, "git", "ls-remote", url, "HEAD")
+	if err != nil {
+		return err
+	}
 
-		if !bytes.Contains(out, []byte("HEAD")) {
-			return fmt.Errorf("not a git repo")
-		}
-		return nil
+	if !bytes.Contains(out, []byte("HEAD")) {
+		return fmt.Errorf("not a git repo")
 	}
-	return probe(git, u, insecure, schemes...)
+	return nil
 }
 
-func probeHgUrl(u *url.URL, insecure bool, schemes []string) (string, error) {
-	hg := func(url *url.URL) error {
-		_, err := run("hg", "identify", url.String())
-		return err
+func isHgRepo(ctx context.Context, url string) error {
+	_, err := runProbe(ctx, "hg", "identify", url)
+	return err
+}
+
+func isBzrRepo(ctx context.Context, url string) error {
+	_, err := runProbe(ctx, "bzr", "info", url)
+	return err
+}
+
+type quietProbesKey struct{}
+
+// quietProbes returns a copy of ctx that makes the repository probes run
+// with it not print the errors of the VCS commands, when most of them are
+// expected to fail.
+func quietProbes(ctx context.Context) context.Context {
+	return context.WithValue(ctx, quietProbesKey{}, true)
+}
+
+// runProbe is like run, but it discards the standard error of the command
+// if ctx was returned by quietProbes.
+func runProbe(ctx context.Context, c string, args ...string) ([]byte, error) {
+	if ctx.Value(quietProbesKey{}) == nil {
+		return run(ctx, c, args...)
 	}
+	var buf bytes.Buffer
+	cmd := exec.Command(c, args...)
+	cmd.Stdout = &buf
+	err := runCmd(ctx, cmd)
+	return buf.Bytes(), err
+}
+
+func probeGitUrl(ctx context.Context, u *url.URL, insecure bool, schemes []string) (string, error) {
+	git := func(url *url.URL) error { return isGitRepo(ctx, url.String()) }
+	return probe(git, u, insecure, schemes...)
+}
+
+func probeHgUrl(ctx context.Context, u *url.URL, insecure bool, schemes []string) (string, error) {
+	hg := func(url *url.URL) error { return isHgRepo(ctx, url.String()) }
 	return probe(hg, u, insecure, schemes...)
 }
 
-func probeBzrUrl(u string) error {
-	bzr := func(url *url.URL) error {
-		_, err := run("bzr", "info", url.String())
-		return err
-	}
+func probeBzrUrl(ctx context.Context, u string) error {
+	bzr := func(url *url.URL) error { return isBzrRepo(ctx, url.String()) }
 	url, err := url.Parse(u)
 	if err != nil {
 		return err
 	}
-	_, err = probe(bzr, url, false, "https")
+	scheme := "https"
+	if url.Scheme == "file" {
+		scheme = "file"
+	}
+	_, err = probe(bzr, url, false, scheme)
 	return err
 }
 
@@ -273,21 +376,23 @@ func probe(vcs func(*url.URL) error, url *url.URL, insecure bool, schemes ...str
 		url.Scheme = scheme
 
 		switch url.Scheme {
-		case "git+ssh", "https", "ssh":
-			if err := vcs(&url); err == nil {
-				return url.String(), nil
-			}
+		case "git+ssh", "https", "ssh", "file":
 		case "http", "git":
 			if !insecure {
 				log.Printf("skipping insecure protocol: %s", url.String())
 				continue
 			}
-			if err := vcs(&url); err == nil {
-				return url.String(), nil
-			}
 		default:
 			return "", fmt.Errorf("unsupported scheme: %v", url.Scheme)
 		}
+		err := vcs(&url)
+		switch {
+		case err == nil:
+			return url.String(), nil
+		case IsTimeout(err), err == ErrInterrupted, err == context.Canceled:
+			// a stalled or killed probe says nothing about the repository
+			return "", err
+		}
 		unsuccessful = append(unsuccessful, url.String())
 	}
 	return "", fmt.Errorf("vcs probe failed, tried: %s", strings.Join(unsuccessful, ","))
@@ -308,10 +413,27 @@ func (g *gitrepo) Type() string {
 	return "git"
 }
 
+func (g *gitrepo) Tags(ctx context.Context) ([]string, error) {
+	out, err := run(ctx, "git", "ls-remote", "--tags", g.url)
+	if err != nil {
+		return nil, err
+	}
+	var tags []string
+	for _, line := range splitLines(out) {
+		// <hash>\trefs/tags/<name>, followed by <name>^{} for annotated tags
+		f := strings.Fields(line)
+		if len(f) != 2 || !strings.HasPrefix(f[1], "refs/tags/") || strings.HasSuffix(f[1], "^{}") {
+			continue
+		}
+		tags = append(tags, strings.TrimPrefix(f[1], "refs/tags/"))
+	}
+	return tags, nil
+}
+
 // Checkout fetchs the remote branch, tag, or revision. If the branch is blank,
 // then the default remote branch will be used. If the branch is "HEAD" and
 // revision is empty, an impossible update is assumed.
-func (g *gitrepo) Checkout(branch, tag, revision string) (WorkingCopy, error) {
+func (g *gitrepo) Checkout(ctx context.Context, branch, tag, revision string) (WorkingCopy, error) {
 	if branch == "HEAD" && revision == "" {
 		return nil, fmt.Errorf("cannot update %q as it has been previously fetched with -tag or -revision. Please use gvt delete then fetch again.", g.url)
 	}
@@ -321,6 +443,16 @@ func (g *gitrepo) Checkout(branch, tag, revision string) (WorkingCopy, error) {
 	if !atMostOne(branch, tag) {
 		return nil, fmt.Errorf("only one of branch or tag may be supplied")
 	}
+	src := g.url
+	if CacheDir != "" {
+		mirror, lock, err := gitMirror(ctx, g.url, revision)
+		if err != nil {
+			return nil, err
+		}
+		defer lock.Unlock()
+		src = mirror
+	}
+
 	dir, err := mktmp()
 	if err != nil {
 		return nil, err
@@ -329,11 +461,14 @@ func (g *gitrepo) Checkout(branch, tag, revision string) (WorkingCopy, error) {
 		path: dir,
 	}
 
+	// shallow clones only work with URLs, see man 1 git-clone
+	shallow := !filepath.IsAbs(src)
+
 	quiet := false
 	args := []string{
 		"clone",
 		"-q", // silence progress report to stderr
-		g.url,
+		src,
 		dir,
 	}
 	if branch != "" && branch != "HEAD" {
@@ -342,16 +477,15 @@ func (g *gitrepo) Checkout(branch, tag, revision string) (WorkingCopy, error) {
 	if tag != "" {
 		quiet = true // git REALLY wants to tell you how awesome 'detached HEAD' is...
 		args = append(args, "--branch", tag, "--single-branch")
-		args = append(args, "--depth", "1")
 	}
-	if revision == "" {
+	if revision == "" && shallow {
 		args = append(args, "--depth", "1")
 	}
 
 	if quiet {
-		err = runQuiet("git", args...)
+		err = runQuiet(ctx, "git", args...)
 	} else {
-		_, err = run("git", args...)
+		_, err = run(ctx, "git", args...)
 	}
 	if err != nil {
 		wc.Destroy()
@@ -359,7 +493,7 @@ func (g *gitrepo) Checkout(branch, tag, revision string) (WorkingCopy, error) {
 	}
 
 	if revision != "" {
-		if err := runOutPath(os.Stderr, dir, "git", "checkout", "-q", revision); err != nil {
+		if err := runOutPath(ctx, os.Stderr, dir, "git", "checkout", "-q", revision); err != nil {
 			wc.Destroy()
 			return nil, err
 		}
@@ -383,22 +517,45 @@ type GitClone struct {
 	workingcopy
 }
 
-func (g *GitClone) Revision() (string, error) {
-	rev, err := runPath(g.path, "git", "rev-parse", "HEAD")
+func (g *GitClone) Revision(ctx context.Context) (string, error) {
+	rev, err := runPath(ctx, g.path, "git", "rev-parse", "HEAD")
 	return strings.TrimSpace(string(rev)), err
 }
 
-func (g *GitClone) Branch() (string, error) {
-	rev, err := runPath(g.path, "git", "rev-parse", "--abbrev-ref", "HEAD")
+func (g *GitClone) Branch(ctx context.Context) (string, error) {
+	rev, err := runPath(ctx, g.path, "git", "rev-parse", "--abbrev-ref", "HEAD")
 	return strings.TrimSpace(string(rev)), err
 }
 
+func (g *GitClone) CommitTime(ctx context.Context) (time.Time, error) {
+	out, err := runPath(ctx, g.path, "git", "log", "-1", "--format=%ct", "HEAD")
+	if err != nil {
+		return time.Time{}, err
+	}
+	return parseUnixTime(strings.TrimSpace(string(out)))
+}
+
+func (g *GitClone) Tags(ctx context.Context) (head, merged []string, err error) {
+	out, err := runPath(ctx, g.path, "git", "tag", "--points-at", "HEAD")
+	if err != nil {
+		return nil, nil, err
+	}
+	head = splitLines(out)
+	out, err = runPath(ctx, g.path, "git", "tag", "--merged", "HEAD")
+	return head, splitLines(out), err
+}
+
+func (g *GitClone) Log(ctx context.Context, from, to string) ([]string, error) {
+	out, err := runPath(ctx, g.path, "git", "log", "--format=%s", from+".."+to)
+	return splitLines(out), err
+}
+
 // Hgrepo returns a RemoteRepo representing a remote git repository.
-func Hgrepo(u *url.URL, insecure bool, schemes ...string) (RemoteRepo, error) {
+func Hgrepo(ctx context.Context, u *url.URL, insecure bool, schemes ...string) (RemoteRepo, error) {
 	if len(schemes) == 0 {
 		schemes = []string{"https", "http"}
 	}
-	url, err := probeHgUrl(u, insecure, schemes)
+	url, err := probeHgUrl(ctx, u, insecure, schemes)
 	if err != nil {
 		return nil, err
 	}
@@ -417,30 +574,42 @@ type hgrepo struct {
 func (h *hgrepo) URL() string  { return h.url }
 func (h *hgrepo) Type() string { return "hg" }
 
-func (h *hgrepo) Checkout(branch, tag, revision string) (WorkingCopy, error) {
+func (h *hgrepo) Tags(ctx context.Context) ([]string, error) {
+	return nil, fmt.Errorf("listing tags is not supported for hg repositories")
+}
+
+func (h *hgrepo) Checkout(ctx context.Context, branch, tag, revision string) (WorkingCopy, error) {
 	if !atMostOne(tag, revision) {
 		return nil, fmt.Errorf("only one of tag or revision may be supplied")
 	}
+	src := h.url
+	if CacheDir != "" {
+		mirror, lock, err := hgMirror(ctx, h.url, revision)
+		if err != nil {
+			return nil, err
+		}
+		defer lock.Unlock()
+		src = mirror
+	}
 	dir, err := mktmp()
 	if err != nil {
 		return nil, err
 	}
 	args := []string{
 		"clone",
-		h.url,
+		src,
 		dir,
-		"--noninteractive",
 	}
 
 	if branch != "" {
 		args = append(args, "--branch", branch)
 	}
-	if err := runOut(os.Stderr, "hg", args...); err != nil {
+	if err := runOut(ctx, os.Stderr, "hg", args...); err != nil {
 		fileutils.RemoveAll(dir)
 		return nil, err
 	}
 	if revision != "" {
-		if err := runOut(os.Stderr, "hg", "--cwd", dir, "update", "-r", revision); err != nil {
+		if err := runOut(ctx, os.Stderr, "hg", "--cwd", dir, "update", "-r", revision); err != nil {
 			fileutils.RemoveAll(dir)
 			return nil, err
 		}
@@ -458,19 +627,48 @@ type HgClone struct {
 	workingcopy
 }
 
-func (h *HgClone) Revision() (string, error) {
-	rev, err := run("hg", "--cwd", h.path, "id", "-i")
+func (h *HgClone) Revision(ctx context.Context) (string, error) {
+	rev, err := run(ctx, "hg", "--cwd", h.path, "id", "-i")
 	return strings.TrimSpace(string(rev)), err
 }
 
-func (h *HgClone) Branch() (string, error) {
-	rev, err := run("hg", "--cwd", h.path, "branch")
+func (h *HgClone) Branch(ctx context.Context) (string, error) {
+	rev, err := run(ctx, "hg", "--cwd", h.path, "branch")
 	return strings.TrimSpace(string(rev)), err
 }
 
+func (h *HgClone) CommitTime(ctx context.Context) (time.Time, error) {
+	out, err := run(ctx, "hg", "--cwd", h.path, "log", "-r", ".", "--template", "{date|hgdate}")
+	if err != nil {
+		return time.Time{}, err
+	}
+	// hgdate is "<unix time> <timezone offset>"
+	f := strings.Fields(string(out))
+	if len(f) == 0 {
+		return time.Time{}, fmt.Errorf("hg log returned no date")
+	}
+	return parseUnixTime(f[0])
+}
+
+func (h *HgClone) Tags(ctx context.Context) (head, merged []string, err error) {
+	out, err := run(ctx, "hg", "--cwd", h.path, "log", "-r", ".", "--template", "{join(tags, '\\n')}")
+	if err != nil {
+		return nil, nil, err
+	}
+	head = splitLines(out)
+	out, err = run(ctx, "hg", "--cwd", h.path, "log", "-r", "ancestors(.) and tag()", "--template", "{join(tags, '\\n')}\n")
+	return head, splitLines(out), err
+}
+
+func (h *HgClone) Log(ctx context.Context, from, to string) ([]string, error) {
+	out, err := run(ctx, "hg", "--cwd", h.path, "log", "-r", fmt.Sprintf("reverse(only(%s, %s))", to, from),
+		"--template", "{desc|firstline}\n")
+	return splitLines(out), err
+}
+
 // Bzrrepo returns a RemoteRepo representing a remote bzr repository.
-func Bzrrepo(url string) (RemoteRepo, error) {
-	if err := probeBzrUrl(url); err != nil {
+func Bzrrepo(ctx context.Context, url string) (RemoteRepo, error) {
+	if err := probeBzrUrl(ctx, url); err != nil {
 		return nil, err
 	}
 	return &bzrrepo{
@@ -493,7 +691,11 @@ func (b *bzrrepo) Type() string {
 	return "bzr"
 }
 
-func (b *bzrrepo) Checkout(branch, tag, revision string) (WorkingCopy, error) {
+func (b *bzrrepo) Tags(ctx context.Context) ([]string, error) {
+	return nil, fmt.Errorf("listing tags is not supported for bzr repositories")
+}
+
+func (b *bzrrepo) Checkout(ctx context.Context, branch, tag, revision string) (WorkingCopy, error) {
 	if !atMostOne(tag, revision) {
 		return nil, fmt.Errorf("only one of tag or revision may be supplied")
 	}
@@ -502,7 +704,7 @@ func (b *bzrrepo) Checkout(branch, tag, revision string) (WorkingCopy, error) {
 		return nil, err
 	}
 	wc := filepath.Join(dir, "wc")
-	if err := runOut(os.Stderr, "bzr", "branch", b.url, wc); err != nil {
+	if err := runOut(ctx, os.Stderr, "bzr", "branch", b.url, wc); err != nil {
 		fileutils.RemoveAll(dir)
 		return nil, err
 	}
@@ -519,14 +721,26 @@ type BzrClone struct {
 	workingcopy
 }
 
-func (b *BzrClone) Revision() (string, error) {
+func (b *BzrClone) Revision(ctx context.Context) (string, error) {
 	return "1", nil
 }
 
-func (b *BzrClone) Branch() (string, error) {
+func (b *BzrClone) Branch(ctx context.Context) (string, error) {
 	return "master", nil
 }
 
+func (b *BzrClone) CommitTime(ctx context.Context) (time.Time, error) {
+	return time.Time{}, fmt.Errorf("commit times are not supported for bzr repositories")
+}
+
+func (b *BzrClone) Tags(ctx context.Context) (head, merged []string, err error) {
+	return nil, nil, fmt.Errorf("tags are not supported for bzr repositories")
+}
+
+func (b *BzrClone) Log(ctx context.Context, from, to string) ([]string, error) {
+	return nil, fmt.Errorf("logs are not supported for bzr repositories")
+}
+
 func (b *BzrClone) Destroy() error {
 	if err := (workingcopy{b.path}).Destroy(); err != nil {
 		return err
@@ -535,6 +749,24 @@ func (b *BzrClone) Destroy() error {
 	return os.Remove(parent)
 }
 
+func parseUnixTime(s string) (time.Time, error) {
+	sec, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid commit time %q", s)
+	}
+	return time.Unix(sec, 0).UTC(), nil
+}
+
+func splitLines(out []byte) []string {
+	var lines []string
+	for _, l := range strings.Split(string(out), "\n") {
+		if l != "" {
+			lines = append(lines, l)
+		}
+	}
+	return lines
+}
+
 func cleanPath(path string) error {
 	if files, _ := ioutil.ReadDir(path); len(files) > 0 || filepath.Base(path) == "vendor" {
 		return nil
@@ -546,45 +778,49 @@ func cleanPath(path string) error {
 	return cleanPath(parent)
 }
 
+// TempDirPrefix starts the names of the temporary folders of the working
+// copies, followed by the pid of the gvt process that created them and a dash.
+const TempDirPrefix = "gvt-"
+
 func mktmp() (string, error) {
-	return ioutil.TempDir("", "gvt-")
+	return ioutil.TempDir("", fmt.Sprintf("%s%d-", TempDirPrefix, os.Getpid()))
 }
 
-func run(c string, args ...string) ([]byte, error) {
+func run(ctx context.Context, c string, args ...string) ([]byte, error) {
 	var buf bytes.Buffer
-	err := runOut(&buf, c, args...)
+	err := runOut(ctx, &buf, c, args...)
 	return buf.Bytes(), err
 }
 
-func runOut(w io.Writer, c string, args ...string) error {
+func runOut(ctx context.Context, w io.Writer, c string, args ...string) error {
 	cmd := exec.Command(c, args...)
 	cmd.Stdin = nil
 	cmd.Stdout = w
 	cmd.Stderr = os.Stderr
-	return cmd.Run()
+	return runCmd(ctx, cmd)
 }
 
-func runQuiet(c string, args ...string) error {
+func runQuiet(ctx context.Context, c string, args ...string) error {
 	cmd := exec.Command(c, args...)
 	cmd.Stdin = nil
 	cmd.Stdout = nil
 	cmd.Stderr = nil
-	return cmd.Run()
+	return runCmd(ctx, cmd)
 }
 
-func runPath(path string, c string, args ...string) ([]byte, error) {
+func runPath(ctx context.Context, path string, c string, args ...string) ([]byte, error) {
 	var buf bytes.Buffer
-	err := runOutPath(&buf, path, c, args...)
+	err := runOutPath(ctx, &buf, path, c, args...)
 	return buf.Bytes(), err
 }
 
-func runOutPath(w io.Writer, path string, c string, args ...string) error {
+func runOutPath(ctx context.Context, w io.Writer, path string, c string, args ...string) error {
 	cmd := exec.Command(c, args...)
 	cmd.Dir = path
 	cmd.Stdin = nil
 	cmd.Stdout = w
 	cmd.Stderr = os.Stderr
-	return cmd.Run()
+	return runCmd(ctx, cmd)
 }
 
 // atMostOne returns true if no more than one string supplied is not empty.
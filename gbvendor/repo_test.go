@@ -1,6 +1,7 @@
 package vendor
 
 import (
+	"context"
 	"fmt"
 	"reflect"
 	"testing"
@@ -116,7 +117,7 @@ func TestDeduceRemoteRepo(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(fmt.Sprintf("DeduceRemoteRepo(%q, %v)", tt.path, tt.insecure), func(t *testing.T) {
-			got, extra, err := DeduceRemoteRepo(tt.path, tt.insecure)
+			got, extra, err := DeduceRemoteRepo(context.Background(), tt.path, tt.insecure)
 			if !reflect.DeepEqual(err, tt.err) {
 				t.Fatalf("DeduceRemoteRepo(%q): want err: %v, got err: %v", tt.path, tt.err, err)
 			}
@@ -125,7 +126,7 @@ func TestDeduceRemoteRepo(t *testing.T) {
 			}
 
 			if tt.want != nil {
-				got, err := NewRemoteRepo(tt.want.URL(), tt.want.Type(), tt.insecure)
+				got, err := NewRemoteRepo(context.Background(), tt.want.URL(), tt.want.Type(), tt.insecure)
 				if err != nil {
 					t.Fatal(err)
 				}
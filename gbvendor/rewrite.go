@@ -0,0 +1,196 @@
+package vendor
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// RewriteRule redirects the repositories of the import paths starting
+// with Prefix to URLs starting with Replacement.
+type RewriteRule struct {
+	Prefix      string
+	Replacement string
+}
+
+// RewriteRules is a list of RewriteRule, in order of precedence.
+type RewriteRules []RewriteRule
+
+// Rewrites are the rules applied by DeduceRemoteRepo and NewRemoteRepo.
+var Rewrites RewriteRules
+
+// ParseRewriteRules parses rewrite rules, one per line in the form
+//
+//	prefix => replacement
+//
+// Empty lines and lines starting with # are ignored.
+func ParseRewriteRules(r io.Reader) (RewriteRules, error) {
+	var rules RewriteRules
+	s := bufio.NewScanner(r)
+	for n := 1; s.Scan(); n++ {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		parts := strings.Split(line, "=>")
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("line %d: expected \"prefix => replacement\"", n)
+		}
+		rule := RewriteRule{
+			Prefix:      strings.TrimSpace(parts[0]),
+			Replacement: strings.TrimSpace(parts[1]),
+		}
+		if rule.Prefix == "" || rule.Replacement == "" {
+			return nil, fmt.Errorf("line %d: empty prefix or replacement", n)
+		}
+		rules = append(rules, rule)
+	}
+	return rules, s.Err()
+}
+
+// ReadRewriteRules reads the rewrite rules from the files at paths, in order
+// of precedence: a rule wins over the ones with the same prefix read after it.
+// Files that don't exist are skipped.
+func ReadRewriteRules(paths ...string) (RewriteRules, error) {
+	var rules RewriteRules
+	for _, p := range paths {
+		f, err := os.Open(p)
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		r, err := ParseRewriteRules(f)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", p, err)
+		}
+		rules = append(rules, r...)
+	}
+	return rules, nil
+}
+
+// Rewrite applies the first rule with the longest prefix matching path,
+// an import path or a repository URL without scheme. It returns the empty
+// string if no rule matches.
+func (rr RewriteRules) Rewrite(path string) string {
+	var best *RewriteRule
+	for i, r := range rr {
+		if !strings.HasPrefix(path, r.Prefix) {
+			continue
+		}
+		if best == nil || len(r.Prefix) > len(best.Prefix) {
+			best = &rr[i]
+		}
+	}
+	if best == nil {
+		return ""
+	}
+	return best.Replacement + strings.TrimPrefix(path, best.Prefix)
+}
+
+// mirrorRepo is a RemoteRepo fetched from a mirror of its canonical URL.
+type mirrorRepo struct {
+	RemoteRepo // the mirror
+	canonical  string
+}
+
+func (m *mirrorRepo) URL() string { return m.canonical }
+
+// MirrorURL returns the URL repo is fetched from, if it's a mirror of
+// repo.URL(), or the empty string.
+func MirrorURL(repo RemoteRepo) string {
+	if m, ok := repo.(*mirrorRepo); ok {
+		return m.RemoteRepo.URL()
+	}
+	return ""
+}
+
+// NewMirrorRepo returns a RemoteRepo for the repository at canonicalURL,
+// fetched from mirrorURL.
+func NewMirrorRepo(ctx context.Context, canonicalURL, mirrorURL, vcs string, insecure bool) (RemoteRepo, error) {
+	mirror, err := newRemoteRepo(ctx, mirrorURL, vcs, insecure)
+	if err != nil {
+		return nil, fmt.Errorf("mirror %s: %v", mirrorURL, err)
+	}
+	return &mirrorRepo{RemoteRepo: mirror, canonical: canonicalURL}, nil
+}
+
+// deduceMirrorRepo returns the RemoteRepo for path according to Rewrites.
+// ok is false if no rule matches path. The upstream repository is never
+// contacted.
+//
+// If the root of path is known from its host, like for github.com, the
+// repository is fetched from the mirror of the root, and the upstream URL
+// is recorded as its canonical one. Otherwise the prefixes of path matching
+// the rules are tried as mirrors, longest first, and the first that is a
+// repository is used directly.
+func deduceMirrorRepo(ctx context.Context, path string, insecure bool) (repo RemoteRepo, extra string, ok bool, err error) {
+	if Rewrites.Rewrite(path) == "" {
+		return nil, "", false, nil
+	}
+
+	if root, upstream, vcs, ok := knownRepoRoot(path); ok {
+		if mirror := Rewrites.Rewrite(root); mirror != "" {
+			repo, err := NewMirrorRepo(ctx, upstream, mirror, vcs, insecure)
+			return repo, strings.TrimPrefix(path, root), true, err
+		}
+	}
+
+	elems := strings.Split(path, "/")
+	var tried []string
+	for i := len(elems); i >= 1; i-- {
+		root := strings.Join(elems[:i], "/")
+		mirror := Rewrites.Rewrite(root)
+		if mirror == "" {
+			break // shorter prefixes don't match either
+		}
+		tried = append(tried, mirror)
+		repo, err := newRemoteRepo(quietProbes(ctx), mirror, "", insecure)
+		if err != nil {
+			continue
+		}
+		return repo, strings.TrimPrefix(path, root), true, nil
+	}
+	return nil, "", true, fmt.Errorf("no repository found for %s in the mirrors, tried: %s",
+		path, strings.Join(tried, ","))
+}
+
+// KnownRepoRoot returns the root import path of the repository containing
+// path, if it follows from the host of path, like for github.com, without
+// network access.
+func KnownRepoRoot(path string) (string, bool) {
+	root, _, _, ok := knownRepoRoot(path)
+	return root, ok
+}
+
+// knownRepoRoot returns the root import path of the repository containing
+// path, its upstream URL and its type, if they follow from the host of path
+// without network access. vcs is empty if the host supports more than one.
+func knownRepoRoot(path string) (root, upstream, vcs string, ok bool) {
+	switch {
+	case ghregex.MatchString(path):
+		v := ghregex.FindStringSubmatch(path)
+		return v[1], "https://github.com/" + v[2], "git", true
+	case bbregex.MatchString(path):
+		v := bbregex.FindStringSubmatch(path)
+		return v[1], "https://bitbucket.org/" + v[2], "", true
+	case gcregex.MatchString(path):
+		v := gcregex.FindStringSubmatch(path)
+		return v[1], "https://code.google.com/p/" + v[2], "", true
+	case lpregex.MatchString(path):
+		v := lpregex.FindStringSubmatch(path)
+		root = "launchpad.net/" + v[1] + v[2]
+		return root, "https://" + root, "bzr", true
+	case genericre.MatchString(path):
+		v := genericre.FindStringSubmatch(path)
+		if v[5] == "svn" {
+			return "", "", "", false
+		}
+		return v[1], "https://" + v[1], v[5], true
+	}
+	return "", "", "", false
+}
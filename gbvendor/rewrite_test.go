@@ -0,0 +1,139 @@
+package vendor
+
+import (
+	"context"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/FiloSottile/gvt/fileutils"
+)
+
+func TestRewriteRules(t *testing.T) {
+	in := `
+# mirrors
+github.com/ => https://git.internal/mirror/github.com/
+github.com/golang/ => https://git.internal/golang/
+golang.org/x/ => ssh://git.internal/x/
+github.com/ => https://ignored.internal/
+`
+	rules, err := ParseRewriteRules(strings.NewReader(in))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rules) != 4 {
+		t.Fatalf("expected 4 rules, got %v", rules)
+	}
+
+	tests := []struct {
+		path, want string
+	}{
+		{"github.com/pkg/errors", "https://git.internal/mirror/github.com/pkg/errors"},
+		{"github.com/golang/protobuf", "https://git.internal/golang/protobuf"},
+		{"golang.org/x/net", "ssh://git.internal/x/net"},
+		{"gopkg.in/yaml.v2", ""},
+	}
+	for _, tt := range tests {
+		if got := rules.Rewrite(tt.path); got != tt.want {
+			t.Errorf("Rewrite(%q): want %q, got %q", tt.path, tt.want, got)
+		}
+	}
+
+	for _, in := range []string{"github.com/", "github.com/ => ", "a => b => c"} {
+		if _, err := ParseRewriteRules(strings.NewReader(in)); err == nil {
+			t.Errorf("ParseRewriteRules(%q): expected an error", in)
+		}
+	}
+}
+
+func TestDeduceMirrorOnlyRepo(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not found")
+	}
+	mirrors := mktemp(t)
+	defer fileutils.RemoveAll(mirrors)
+	writeFile(t, mirrors, "leaf/sub/sub.go", "package sub\n")
+	git := func(args ...string) {
+		args = append([]string{"-c", "user.name=gvt", "-c", "user.email=gvt@example.com"}, args...)
+		cmd := exec.Command("git", args...)
+		cmd.Dir = filepath.Join(mirrors, "leaf")
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	git("init", "-q")
+	git("add", "-A")
+	git("commit", "-q", "-m", "first")
+
+	defer func(rr RewriteRules) { Rewrites = rr }(Rewrites)
+	Rewrites = RewriteRules{{Prefix: "corp.example/", Replacement: mirrors + "/"}}
+
+	// corp.example doesn't resolve, so the longest prefix that is a
+	// repository in the mirrors is used, and recorded as is
+	repo, extra, err := DeduceRemoteRepo(context.Background(), "corp.example/leaf/sub", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := filepath.Join(mirrors, "leaf"); repo.URL() != want || extra != "/sub" {
+		t.Errorf("want %s and /sub, got %s and %s", want, repo.URL(), extra)
+	}
+	if m := MirrorURL(repo); m != "" {
+		t.Errorf("expected no mirror, got %s", m)
+	}
+}
+
+func TestKnownRepoRoot(t *testing.T) {
+	tests := []struct {
+		path, root, upstream, vcs string
+	}{
+		{"github.com/pkg/errors/sub", "github.com/pkg/errors", "https://github.com/pkg/errors", "git"},
+		{"bitbucket.org/ww/goautoneg", "bitbucket.org/ww/goautoneg", "https://bitbucket.org/ww/goautoneg", ""},
+		{"code.google.com/p/go-uuid/uuid", "code.google.com/p/go-uuid", "https://code.google.com/p/go-uuid", ""},
+		{"launchpad.net/gnuflag", "launchpad.net/gnuflag", "https://launchpad.net/gnuflag", "bzr"},
+		{"git.example.com/foo/bar.git/baz", "git.example.com/foo/bar.git", "https://git.example.com/foo/bar.git", "git"},
+		{"golang.org/x/net/context", "", "", ""},
+	}
+	for _, tt := range tests {
+		root, upstream, vcs, ok := knownRepoRoot(tt.path)
+		if ok != (tt.root != "") || root != tt.root || upstream != tt.upstream || vcs != tt.vcs {
+			t.Errorf("knownRepoRoot(%q): want %q %q %q, got %q %q %q %v",
+				tt.path, tt.root, tt.upstream, tt.vcs, root, upstream, vcs, ok)
+		}
+	}
+}
+
+func TestDeduceKnownHostMirror(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not found")
+	}
+	mirrors := mktemp(t)
+	defer fileutils.RemoveAll(mirrors)
+	writeFile(t, mirrors, "github.com/foo/bar/sub/sub.go", "package sub\n")
+	git := func(args ...string) {
+		args = append([]string{"-c", "user.name=gvt", "-c", "user.email=gvt@example.com"}, args...)
+		cmd := exec.Command("git", args...)
+		cmd.Dir = filepath.Join(mirrors, "github.com", "foo", "bar")
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	git("init", "-q")
+	git("add", "-A")
+	git("commit", "-q", "-m", "first")
+
+	defer func(rr RewriteRules) { Rewrites = rr }(Rewrites)
+	Rewrites = RewriteRules{{Prefix: "github.com/", Replacement: mirrors + "/github.com/"}}
+
+	// the root comes from the github.com pattern, without contacting github.com
+	repo, extra, err := DeduceRemoteRepo(context.Background(), "github.com/foo/bar/sub", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if repo.URL() != "https://github.com/foo/bar" || extra != "/sub" {
+		t.Errorf("want https://github.com/foo/bar and /sub, got %s and %s", repo.URL(), extra)
+	}
+	if want := filepath.Join(mirrors, "github.com", "foo", "bar"); MirrorURL(repo) != want {
+		t.Errorf("want mirror %s, got %s", want, MirrorURL(repo))
+	}
+}
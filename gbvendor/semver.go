@@ -0,0 +1,274 @@
+package vendor
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// semver is a parsed semantic version, like v1.4.2-rc.1.
+type semver struct {
+	major, minor, patch int
+	pre                 string
+}
+
+// parseSemver parses a semantic version with an optional "v" prefix.
+// Minor and patch versions default to zero, and build metadata is ignored.
+// If wildcards is true, missing or "x" components are reported in n, the
+// number of components actually specified.
+func parseSemver(s string, wildcards bool) (v semver, n int, err error) {
+	orig := s
+	s = strings.TrimPrefix(s, "v")
+	if i := strings.Index(s, "+"); i >= 0 {
+		s = s[:i]
+	}
+	if i := strings.Index(s, "-"); i >= 0 {
+		v.pre = s[i+1:]
+		s = s[:i]
+		if v.pre == "" {
+			return semver{}, 0, fmt.Errorf("invalid version %q", orig)
+		}
+	}
+
+	parts := strings.Split(s, ".")
+	if len(parts) > 3 {
+		return semver{}, 0, fmt.Errorf("invalid version %q", orig)
+	}
+	nums := []*int{&v.major, &v.minor, &v.patch}
+	for i, p := range parts {
+		if wildcards && (p == "x" || p == "X" || p == "*") {
+			break
+		}
+		num, err := strconv.Atoi(p)
+		if err != nil || num < 0 {
+			return semver{}, 0, fmt.Errorf("invalid version %q", orig)
+		}
+		*nums[i] = num
+		n++
+	}
+	if n == 0 && !wildcards {
+		return semver{}, 0, fmt.Errorf("invalid version %q", orig)
+	}
+	if n < 3 && v.pre != "" {
+		return semver{}, 0, fmt.Errorf("invalid version %q", orig)
+	}
+	return v, n, nil
+}
+
+// compare returns -1, 0 or 1 if v is respectively lower, equal or higher
+// than w, following the semver precedence rules.
+func (v semver) compare(w semver) int {
+	switch {
+	case v.major != w.major:
+		return cmpInt(v.major, w.major)
+	case v.minor != w.minor:
+		return cmpInt(v.minor, w.minor)
+	case v.patch != w.patch:
+		return cmpInt(v.patch, w.patch)
+	}
+
+	// a version without pre-release is higher than one with it
+	switch {
+	case v.pre == w.pre:
+		return 0
+	case v.pre == "":
+		return 1
+	case w.pre == "":
+		return -1
+	}
+
+	vp, wp := strings.Split(v.pre, "."), strings.Split(w.pre, ".")
+	for i := 0; i < len(vp) && i < len(wp); i++ {
+		if vp[i] == wp[i] {
+			continue
+		}
+		vn, verr := strconv.Atoi(vp[i])
+		wn, werr := strconv.Atoi(wp[i])
+		switch {
+		case verr == nil && werr == nil:
+			return cmpInt(vn, wn)
+		case verr == nil:
+			return -1 // numeric identifiers are lower
+		case werr == nil:
+			return 1
+		case vp[i] < wp[i]:
+			return -1
+		default:
+			return 1
+		}
+	}
+	return cmpInt(len(vp), len(wp))
+}
+
+func cmpInt(a, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+type comparator struct {
+	op string // one of = != < <= > >=
+	v  semver
+}
+
+func (c comparator) match(v semver) bool {
+	r := v.compare(c.v)
+	switch c.op {
+	case "=":
+		return r == 0
+	case "!=":
+		return r != 0
+	case "<":
+		return r < 0
+	case "<=":
+		return r <= 0
+	case ">":
+		return r > 0
+	case ">=":
+		return r >= 0
+	}
+	return false
+}
+
+// Constraint is a set of semantic version ranges.
+type Constraint struct {
+	// alternatives are OR'ed, comparators within each are AND'ed
+	alternatives [][]comparator
+}
+
+// ParseConstraint parses a version constraint. It is a list of ranges
+// separated by "||", each of which is a list of comparisons separated by
+// commas or spaces, all of which must match. Comparisons are a version
+// optionally preceded by one of
+//
+//	= != < <= > >=  the usual comparison operators
+//	^               compatible versions: ^1.4 is >=1.4.0 <2.0.0,
+//	                and ^0.4 is >=0.4.0 <0.5.0
+//	~               patch updates: ~1.4 is >=1.4.0 <1.5.0
+//
+// Versions without an operator can have missing or "x" components, so that
+// 1.4 and 1.4.x are both >=1.4.0 <1.5.0, and * matches any version.
+func ParseConstraint(s string) (Constraint, error) {
+	var c Constraint
+	for _, alt := range strings.Split(s, "||") {
+		var cmps []comparator
+		for _, term := range strings.FieldsFunc(alt, func(r rune) bool { return r == ',' || r == ' ' }) {
+			tc, err := parseTerm(term)
+			if err != nil {
+				return Constraint{}, fmt.Errorf("invalid constraint %q: %v", s, err)
+			}
+			cmps = append(cmps, tc...)
+		}
+		if len(cmps) == 0 {
+			return Constraint{}, fmt.Errorf("invalid constraint %q", s)
+		}
+		c.alternatives = append(c.alternatives, cmps)
+	}
+	return c, nil
+}
+
+func parseTerm(term string) ([]comparator, error) {
+	for _, op := range []string{"!=", "<=", ">=", "<", ">", "=", "^", "~"} {
+		if !strings.HasPrefix(term, op) {
+			continue
+		}
+		v, n, err := parseSemver(term[len(op):], op == "^" || op == "~" || op == "=")
+		if err != nil {
+			return nil, err
+		}
+		switch op {
+		case "^":
+			upper := semver{major: v.major + 1}
+			switch {
+			case v.major == 0 && n >= 2 && (v.minor != 0 || n == 2):
+				upper = semver{minor: v.minor + 1}
+			case v.major == 0 && n == 3:
+				upper = semver{patch: v.patch + 1}
+			}
+			return rangeOf(v, upper), nil
+		case "~":
+			if n < 2 {
+				return rangeOf(v, semver{major: v.major + 1}), nil
+			}
+			return rangeOf(v, semver{major: v.major, minor: v.minor + 1}), nil
+		case "=":
+			return wildcardRange(v, n), nil
+		}
+		return []comparator{{op, v}}, nil
+	}
+	v, n, err := parseSemver(term, true)
+	if err != nil {
+		return nil, err
+	}
+	return wildcardRange(v, n), nil
+}
+
+// wildcardRange returns the range of versions starting with the first n
+// components of v.
+func wildcardRange(v semver, n int) []comparator {
+	switch n {
+	case 0:
+		return []comparator{{">=", semver{}}}
+	case 1:
+		return rangeOf(v, semver{major: v.major + 1})
+	case 2:
+		return rangeOf(v, semver{major: v.major, minor: v.minor + 1})
+	}
+	return []comparator{{"=", v}}
+}
+
+// rangeOf returns the comparators for the versions from lower included
+// to upper excluded. Pre-releases of upper are excluded too.
+func rangeOf(lower, upper semver) []comparator {
+	upper.pre = "0"
+	return []comparator{{">=", lower}, {"<", upper}}
+}
+
+// Match reports whether the version v, with an optional "v" prefix,
+// satisfies the constraint. Pre-release versions only match if a
+// comparison in the same range explicitly mentions a pre-release of the
+// same major.minor.patch version.
+func (c Constraint) Match(v string) bool {
+	sv, _, err := parseSemver(v, false)
+	if err != nil {
+		return false
+	}
+alternatives:
+	for _, alt := range c.alternatives {
+		allowPre := sv.pre == ""
+		for _, cmp := range alt {
+			if !cmp.match(sv) {
+				continue alternatives
+			}
+			if cmp.v.pre != "" && cmp.v.pre != "0" && cmp.v.major == sv.major &&
+				cmp.v.minor == sv.minor && cmp.v.patch == sv.patch {
+				allowPre = true
+			}
+		}
+		if allowPre {
+			return true
+		}
+	}
+	return false
+}
+
+// Best returns the highest of versions that satisfies the constraint,
+// or the empty string if none does.
+func (c Constraint) Best(versions []string) string {
+	var best string
+	var bestV semver
+	for _, v := range versions {
+		if !c.Match(v) {
+			continue
+		}
+		sv, _, _ := parseSemver(v, false)
+		if best == "" || sv.compare(bestV) > 0 {
+			best, bestV = v, sv
+		}
+	}
+	return best
+}
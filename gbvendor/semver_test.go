@@ -0,0 +1,84 @@
+package vendor
+
+import "testing"
+
+func TestConstraintMatch(t *testing.T) {
+	tests := []struct {
+		constraint string
+		version    string
+		want       bool
+	}{
+		{"^1.4", "v1.4.0", true},
+		{"^1.4", "1.9.3", true},
+		{"^1.4", "v1.3.9", false},
+		{"^1.4", "v2.0.0", false},
+		{"^1.4", "v1.5.0-rc.1", false},
+		{"^0.4.2", "v0.4.9", true},
+		{"^0.4.2", "v0.5.0", false},
+		{"^0.0.3", "v0.0.3", true},
+		{"^0.0.3", "v0.0.4", false},
+		{"~1.4", "v1.4.7", true},
+		{"~1.4", "v1.5.0", false},
+		{"~1", "v1.9.0", true},
+		{"1.x", "v1.2.3", true},
+		{"1.x", "v2.0.0", false},
+		{"1.4", "v1.4.2", true},
+		{"1.4.2", "v1.4.2", true},
+		{"1.4.2", "v1.4.3", false},
+		{"*", "v3.1.4", true},
+		{"*", "v3.1.4-beta", false},
+		{">=1.2, <1.4", "v1.3.0", true},
+		{">=1.2 <1.4", "v1.4.0", false},
+		{"!=1.3.0", "v1.3.0", false},
+		{"<1 || >=2", "v0.9.0", true},
+		{"<1 || >=2", "v1.5.0", false},
+		{"<1 || >=2", "v2.0.1", true},
+		{">=1.0.0-rc.1", "v1.0.0-rc.2", true},
+		{">=1.0.0-rc.1", "v1.1.0-rc.2", false},
+		{"^1.0.0-beta", "v1.0.0-beta.2", true},
+		{"^1", "v1.2.3+build.5", true},
+		{"^1", "release-1.2", false},
+		{"^1", "v1.2.3.4", false},
+	}
+	for _, tt := range tests {
+		c, err := ParseConstraint(tt.constraint)
+		if err != nil {
+			t.Errorf("ParseConstraint(%q): %v", tt.constraint, err)
+			continue
+		}
+		if got := c.Match(tt.version); got != tt.want {
+			t.Errorf("ParseConstraint(%q).Match(%q): want %v, got %v", tt.constraint, tt.version, tt.want, got)
+		}
+	}
+}
+
+func TestParseConstraintErrors(t *testing.T) {
+	for _, s := range []string{"", "^", "foo", "1.2.3.4", ">=1.x", "1.0.0 ||", "^1.0-rc"} {
+		if _, err := ParseConstraint(s); err == nil {
+			t.Errorf("ParseConstraint(%q): expected an error", s)
+		}
+	}
+}
+
+func TestConstraintBest(t *testing.T) {
+	tags := []string{"v1.0.0", "v1.4.0", "v1.10.1", "v1.11.0-rc.1", "v2.0.0", "latest", "v0.9.0"}
+	tests := []struct {
+		constraint string
+		want       string
+	}{
+		{"^1.4", "v1.10.1"},
+		{"~1.4", "v1.4.0"},
+		{"<1", "v0.9.0"},
+		{"*", "v2.0.0"},
+		{"^3", ""},
+	}
+	for _, tt := range tests {
+		c, err := ParseConstraint(tt.constraint)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := c.Best(tags); got != tt.want {
+			t.Errorf("ParseConstraint(%q).Best: want %q, got %q", tt.constraint, tt.want, got)
+		}
+	}
+}
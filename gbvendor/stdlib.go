@@ -0,0 +1,43 @@
+package vendor
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// IsStdlibImport reports whether importpath is a package of the standard
+// library of the Go installation at goroot, or the "C" pseudo-package.
+//
+// If goroot doesn't contain the standard library sources, it falls back to
+// assuming that import paths without a dot in the first element are
+// standard library packages.
+func IsStdlibImport(goroot, importpath string) bool {
+	if importpath == "C" {
+		return true
+	}
+
+	src := filepath.Join(goroot, "src")
+	if goroot == "" || !isDir(filepath.Join(src, "fmt")) {
+		elem := strings.SplitN(importpath, "/", 2)[0]
+		return !strings.Contains(elem, ".")
+	}
+
+	if strings.HasPrefix(importpath, "vendor/") || strings.Contains(importpath, "/vendor/") ||
+		strings.HasPrefix(importpath, "cmd/") {
+		// GOROOT/src/vendor and GOROOT/src/cmd are not importable
+		return false
+	}
+	return isDir(filepath.Join(src, filepath.FromSlash(importpath)))
+}
+
+// IsRemoteImportPath reports whether importpath is in a form that can
+// be fetched, that is with a host name as the first element.
+func IsRemoteImportPath(importpath string) bool {
+	return importre.MatchString(importpath)
+}
+
+func isDir(path string) bool {
+	fi, err := os.Stat(path)
+	return err == nil && fi.IsDir()
+}
@@ -0,0 +1,54 @@
+package vendor
+
+import (
+	"go/build"
+	"testing"
+
+	"github.com/FiloSottile/gvt/fileutils"
+)
+
+func TestIsStdlibImport(t *testing.T) {
+	empty := mktemp(t)
+	defer fileutils.RemoveAll(empty)
+
+	tests := []struct {
+		goroot string
+		path   string
+		want   bool
+	}{
+		{build.Default.GOROOT, "fmt", true},
+		{build.Default.GOROOT, "net/http", true},
+		{build.Default.GOROOT, "C", true},
+		{build.Default.GOROOT, "corp/foo", false},
+		{build.Default.GOROOT, "github.com/pkg/errors", false},
+		{build.Default.GOROOT, "golang.org/x/net/http2/hpack", false},
+		{build.Default.GOROOT, "cmd/go", false},
+		{empty, "fmt", true},
+		{empty, "corp/foo", true},
+		{empty, "github.com/pkg/errors", false},
+	}
+	for _, tt := range tests {
+		if got := IsStdlibImport(tt.goroot, tt.path); got != tt.want {
+			t.Errorf("IsStdlibImport(%q, %q): want %v, got %v", tt.goroot, tt.path, tt.want, got)
+		}
+	}
+}
+
+func TestIsRemoteImportPath(t *testing.T) {
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{"github.com/pkg/errors", true},
+		{"git.corp.internal/team/lib", true},
+		{"gopkg.in/yaml.v2", true},
+		{"corp/foo", false},
+		{"appengine", false},
+		{"fmt", false},
+	}
+	for _, tt := range tests {
+		if got := IsRemoteImportPath(tt.path); got != tt.want {
+			t.Errorf("IsRemoteImportPath(%q): want %v, got %v", tt.path, tt.want, got)
+		}
+	}
+}
@@ -64,9 +64,21 @@ Usage:
 
 The commands are:
 {{range .}}
-        {{.Name | printf "%-11s"}} {{.Short}}{{end}}
+        {{.Name | printf "%-15s"}} {{.Short}}{{end}}
 
 Use "gvt help [command]" for more information about a command.
+
+Repositories are downloaded through a cache of mirrors, shared by all the gvt
+processes of the user and updated incrementally. The cache is in $GVTCACHE, by
+default the gvt folder in the user cache folder ($XDG_CACHE_HOME or ~/.cache on
+Linux). Set GVTCACHE=off to disable it, and see "gvt help cache" to manage it.
+
+Every command accepts the -timeout d and -op-timeout d flags, which abort it if
+it runs for longer than the duration d, like 10m, or if any git, hg or bzr command
+it runs does. The VCS commands don't prompt for credentials: git is run with
+GIT_TERMINAL_PROMPT=0 and ssh in BatchMode, and hg with --noninteractive. If an
+ssh command is set with GIT_SSH, GIT_SSH_COMMAND or the core.sshCommand git
+setting, it is used as is, and it should not prompt either.
 `
 
 var documentationTemplate = `// DO NOT EDIT THIS FILE.
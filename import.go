@@ -0,0 +1,172 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/FiloSottile/gvt/gbvendor"
+)
+
+var (
+	importRestore bool // run restore after importing
+)
+
+func addImportFlags(fs *flag.FlagSet) {
+	fs.BoolVar(&importRestore, "restore", false, "restore the imported dependencies")
+	fs.BoolVar(&insecure, "precaire", false, "allow the use of insecure protocols")
+	fs.BoolVar(&tests, "t", false, "vendor _test.go files and testdata")
+	fs.BoolVar(&all, "a", false, "vendor all files and subfolders")
+	fs.UintVar(&rbConnections, "connections", 8, "count of parallel download connections")
+}
+
+var cmdImport = &Command{
+	Name:      "import",
+	UsageLine: "import [-restore] [-connections N] [-precaire] [-t|-a] file",
+	Short:     "import dependencies from another vendoring tool",
+	Long: `import adds to the manifest the dependencies pinned by the lock file of
+another vendoring tool. The format is detected from the file name:
+
+	Godeps.json   godep
+	glide.lock    glide
+	Gopkg.lock    dep
+	vendor.conf   vndr and trash
+
+The pinned revisions and subpackages are preserved. Repositories are resolved
+from the import paths, unless the lock file records a different source.
+
+Dependencies that are already present in the manifest are skipped.
+
+Flags:
+	-restore
+		fetch the imported dependencies into the vendor folder, like restore.
+	-connections
+		count of parallel download connections, with -restore.
+	-t
+		vendor also _test.go files and testdata.
+	-a
+		vendor all files and subfolders, ignoring ONLY .git, .hg and .bzr.
+	-precaire
+		allow the use of insecure protocols.
+
+`,
+	Run: func(args []string) error {
+		switch len(args) {
+		case 0:
+			return fmt.Errorf("import: lock file missing")
+		case 1:
+			return importLockFile(args[0])
+		default:
+			return fmt.Errorf("more than one lock file supplied")
+		}
+	},
+	AddFlags:   addImportFlags,
+	LockVendor: true,
+}
+
+func importLockFile(path string) error {
+	locked, err := vendor.ParseLockFile(path)
+	if err != nil {
+		return err
+	}
+	// so that parents come before their subpackages
+	sort.Sort(byLockedImportpath(locked))
+
+	m, err := vendor.ReadManifest(manifestFile)
+	if err != nil {
+		return fmt.Errorf("could not load manifest: %v", err)
+	}
+
+	for _, l := range locked {
+		if d, err := m.GetDependencyForImportpath(l.Importpath); err == nil {
+			if d.Importpath != l.Importpath && d.Revision != l.Revision {
+				log.Printf("WARNING: %s is pinned at %s, but it's vendored as part of %s at %s",
+					l.Importpath, l.Revision, d.Importpath, d.Revision)
+			} else if d.Importpath == l.Importpath {
+				log.Println("Skipping (existing):", l.Importpath)
+			}
+			continue
+		}
+		if subs := m.GetSubpackages(l.Importpath); len(subs) > 0 {
+			return fmt.Errorf("subpackages of %s are already vendored, delete them first: %s",
+				l.Importpath, subs[0].Importpath)
+		}
+
+		log.Println("Importing:", l.Importpath)
+		dep, err := resolveLockedDependency(l)
+		if err != nil {
+			return fmt.Errorf("%s: %v", l.Importpath, err)
+		}
+		if err := m.AddDependency(dep); err != nil {
+			return err
+		}
+	}
+
+	if err := vendor.WriteManifest(manifestFile, m); err != nil {
+		return err
+	}
+
+	if importRestore {
+		rbInsecure = insecure
+		return restore(manifestFile)
+	}
+	return nil
+}
+
+var commitHash = regexp.MustCompile(`^[0-9a-fA-F]{12,64}$`)
+
+// resolveLockedDependency finds the repository of a locked package, and
+// turns its revision into a commit hash if the lock file pinned a tag.
+func resolveLockedDependency(l vendor.LockedDependency) (vendor.Dependency, error) {
+	var repo vendor.RemoteRepo
+	var extra string
+	var err error
+	if l.Repository != "" {
+		if l.Root == "" || !contains(l.Root, l.Importpath) {
+			return vendor.Dependency{}, fmt.Errorf("unable to derive the root repo import path")
+		}
+		if p := l.RepositoryPath(); p != "" {
+			repo, _, err = GlobalDownloader.DeduceRemoteRepo(p, insecure)
+		} else {
+			repo, err = vendor.NewRemoteRepo(cmdCtx, l.Repository, l.VCS, insecure)
+		}
+		extra = strings.TrimPrefix(l.Importpath, l.Root)
+	} else {
+		repo, extra, err = GlobalDownloader.DeduceRemoteRepo(l.Importpath, insecure)
+	}
+	if err != nil {
+		return vendor.Dependency{}, err
+	}
+
+	rev := l.Revision
+	if !commitHash.MatchString(rev) {
+		wc, err := GlobalDownloader.Get(repo, "", "", rev)
+		if err != nil {
+			return vendor.Dependency{}, err
+		}
+		if rev, err = wc.Revision(cmdCtx); err != nil {
+			return vendor.Dependency{}, err
+		}
+	}
+
+	return vendor.Dependency{
+		Importpath: l.Importpath,
+		Repository: repo.URL(),
+		Mirror:     vendor.MirrorURL(repo),
+		VCS:        repo.Type(),
+		Revision:   rev,
+		Branch:     l.Branch,
+		Path:       extra,
+		NoTests:    !tests,
+		AllFiles:   all,
+	}, nil
+}
+
+type byLockedImportpath []vendor.LockedDependency
+
+func (s byLockedImportpath) Len() int           { return len(s) }
+func (s byLockedImportpath) Less(i, j int) bool { return s[i].Importpath < s[j].Importpath }
+func (s byLockedImportpath) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
@@ -14,8 +14,11 @@ var (
 	format string
 )
 
+const defaultListFormat = "{{.Importpath}}\t{{.Repository}}{{.Path}}\t{{.Branch}}\t{{.Revision}}" +
+	"{{if .Pinned}}\tpinned{{with .PinReason}}: {{.}}{{end}}{{end}}"
+
 func addListFlags(fs *flag.FlagSet) {
-	fs.StringVar(&format, "f", "{{.Importpath}}\t{{.Repository}}{{.Path}}\t{{.Branch}}\t{{.Revision}}", "format template")
+	fs.StringVar(&format, "f", defaultListFormat, "format template")
 }
 
 var cmdList = &Command{
@@ -28,6 +31,7 @@ Flags:
 	-f
 		controls the template used for printing each manifest entry. If not supplied
 		the default value is "{{.Importpath}}\t{{.Repository}}{{.Path}}\t{{.Branch}}\t{{.Revision}}"
+		followed by "\tpinned: reason" for pinned dependencies.
 
 `,
 	Run: func(args []string) error {
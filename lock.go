@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/FiloSottile/gvt/fileutils"
+)
+
+const (
+	lockTimeout      = 10 * time.Minute
+	lockPollInterval = 200 * time.Millisecond
+)
+
+// vendorLock is the exclusive lock held on vendorDir by commands that modify it.
+type vendorLock struct {
+	lock       *fileutils.Lock
+	createdDir bool // vendorDir did not exist before locking
+}
+
+// lockVendor acquires the lock on vendorDir, waiting up to lockTimeout for
+// other gvt processes to release it.
+func lockVendor() (*vendorLock, error) {
+	vl := &vendorLock{}
+	if _, err := os.Stat(vendorDir); os.IsNotExist(err) {
+		if err := os.MkdirAll(vendorDir, 0755); err != nil {
+			return nil, err
+		}
+		vl.createdDir = true
+	}
+
+	path := filepath.Join(vendorDir, ".gvt.lock")
+	deadline := time.Now().Add(lockTimeout)
+	waiting := false
+	for {
+		l, err := fileutils.TryLock(path)
+		if err == nil {
+			vl.lock = l
+			return vl, nil
+		}
+		if err != fileutils.ErrLocked {
+			return nil, fmt.Errorf("could not lock %s: %v", vendorDir, err)
+		}
+		if err := cmdCtx.Err(); err != nil {
+			return nil, fmt.Errorf("waiting for the release of %s: %v", path, err)
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("timed out after %v waiting for another gvt process to release %s", lockTimeout, path)
+		}
+		if !waiting {
+			log.Printf("Waiting for another gvt process to release %s", path)
+			waiting = true
+		}
+		time.Sleep(lockPollInterval)
+	}
+}
+
+// Unlock releases the lock, and removes vendorDir if it was created just for
+// the lock and nothing was put in it.
+func (vl *vendorLock) Unlock() error {
+	if err := vl.lock.Unlock(); err != nil {
+		return err
+	}
+	if vl.createdDir {
+		os.Remove(vendorDir) // fails if not empty
+	}
+	return nil
+}
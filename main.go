@@ -1,13 +1,20 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"go/build"
 	"log"
 	"os"
+	"os/signal"
 	"path/filepath"
 	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/FiloSottile/gvt/gbvendor"
 )
 
 var fs = flag.NewFlagSet(os.Args[0], flag.ContinueOnError)
@@ -23,6 +30,10 @@ type Command struct {
 	Long      string
 	Run       func(args []string) error
 	AddFlags  func(fs *flag.FlagSet)
+
+	// LockVendor is set by commands that modify the vendor folder, which
+	// then run holding an exclusive lock on it.
+	LockVendor bool
 }
 
 var commands = []*Command{
@@ -31,6 +42,16 @@ var commands = []*Command{
 	cmdUpdate,
 	cmdList,
 	cmdDelete,
+	cmdPin,
+	cmdUnpin,
+	cmdVerify,
+	cmdCheck,
+	cmdExport,
+	cmdImport,
+	cmdDiff,
+	cmdMergeManifest,
+	cmdCache,
+	cmdClean,
 }
 
 func main() {
@@ -51,6 +72,8 @@ func main() {
 	for _, command := range commands {
 		if command.Name == args[0] {
 
+			addTimeoutFlags(fs)
+
 			// add extra flags if necessary
 			if command.AddFlags != nil {
 				command.AddFlags(fs)
@@ -66,13 +89,41 @@ func main() {
 				os.Exit(3)
 			}
 
-			if err := command.Run(fs.Args()); err != nil {
-				log.Fatalf("command %q failed: %v", command.Name, err)
+			rules, err := vendor.ReadRewriteRules(rewriteRulesFiles()...)
+			if err != nil {
+				fatalf("could not load the rewrite rules: %v", err)
+			}
+			vendor.Rewrites = rules
+			vendor.CacheDir = cacheDir()
+			vendor.CommandTimeout = opTimeout
+			if timeout > 0 {
+				cmdCtx, cancelCmd = context.WithTimeout(context.Background(), timeout)
+			} else {
+				cmdCtx, cancelCmd = context.WithCancel(context.Background())
 			}
-			if err := GlobalDownloader.Flush(); err != nil {
-				log.Fatalf("failed to delete tempdirs: %v", err)
+
+			if dryRunJSON && !dryRun {
+				fatalf("command %q failed: -json can only be used with -n", command.Name)
+			}
+
+			handleSignals()
+
+			// dry runs don't modify the vendor folder, so they don't need the lock
+			if command.LockVendor && !dryRun {
+				l, err := lockVendor()
+				if err != nil {
+					fatalf("command %q failed: %v", command.Name, err)
+				}
+				setHeldLock(l)
 			}
-			return
+
+			if err := command.Run(fs.Args()); err != nil {
+				if cmdCtx.Err() == context.DeadlineExceeded {
+					fatalf("command %q timed out after %v: %v", command.Name, timeout, err)
+				}
+				fatalf("command %q failed: %v", command.Name, err)
+			}
+			exit(0)
 		}
 	}
 	fmt.Fprintf(os.Stderr, "unknown command: %q\n\n", args[0])
@@ -85,10 +136,25 @@ var (
 	importPath              string
 )
 
+var (
+	timeout   time.Duration // deadline of the whole command
+	opTimeout time.Duration // deadline of each VCS command
+
+	// cmdCtx is the context of the VCS commands run by the command, done
+	// once timeout expires or cancelCmd is called.
+	cmdCtx    = context.Background()
+	cancelCmd = func() {}
+)
+
+func addTimeoutFlags(fs *flag.FlagSet) {
+	fs.DurationVar(&timeout, "timeout", 0, "abort the command if it takes longer than this")
+	fs.DurationVar(&opTimeout, "op-timeout", 0, "abort each VCS operation that takes longer than this")
+}
+
 func init() {
 	wd, err := os.Getwd()
 	if err != nil {
-		log.Fatal(err)
+		fatalf("%v", err)
 	}
 	vendorDir = filepath.Join(wd, "vendor")
 	manifestFile = filepath.Join(vendorDir, "manifest")
@@ -112,3 +178,67 @@ func init() {
 		}
 	}
 }
+
+var (
+	cleanupOnce sync.Once
+	heldLockMu  sync.Mutex
+	heldLock    *vendorLock // the vendor lock, released by cleanup
+)
+
+func setHeldLock(l *vendorLock) {
+	heldLockMu.Lock()
+	heldLock = l
+	heldLockMu.Unlock()
+}
+
+// cleanup releases the vendor lock and destroys the working copies of
+// GlobalDownloader. It reports whether it succeeded.
+func cleanup() bool {
+	ok := true
+	heldLockMu.Lock()
+	if heldLock != nil {
+		if err := heldLock.Unlock(); err != nil {
+			log.Printf("failed to release the vendor lock: %v", err)
+		}
+		heldLock = nil
+	}
+	heldLockMu.Unlock()
+	cancelCmd()
+	if err := GlobalDownloader.Flush(); err != nil {
+		log.Printf("failed to delete tempdirs: %v", err)
+		ok = false
+	}
+	return ok
+}
+
+// exit is the single exit path of the commands: it runs cleanup, once even
+// if called concurrently, and exits with code, or 1 if cleanup failed.
+func exit(code int) {
+	// concurrent callers block here until the first one exits
+	cleanupOnce.Do(func() {
+		if !cleanup() && code == 0 {
+			code = 1
+		}
+		os.Exit(code)
+	})
+}
+
+// fatalf is like log.Fatalf, but exits through exit.
+func fatalf(format string, v ...interface{}) {
+	log.Printf(format, v...)
+	exit(1)
+}
+
+// handleSignals makes SIGINT and SIGTERM kill the running VCS commands and
+// exit through exit. A second signal exits immediately.
+func handleSignals() {
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		sig := <-c
+		signal.Stop(c)
+		log.Printf("Received %v, cleaning up", sig)
+		vendor.Interrupt()
+		exit(1)
+	}()
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/FiloSottile/gvt/gbvendor"
+)
+
+var cmdMergeManifest = &Command{
+	Name:      "merge-manifest",
+	UsageLine: "merge-manifest base ours theirs",
+	Short:     "merge manifests, as a git merge driver",
+	Long: `merge-manifest does a three-way merge of the dependencies in the manifests
+ours and theirs, which share the common ancestor base, and writes the result
+to ours.
+
+Dependencies changed on only one side are merged automatically. If both sides
+changed the same dependency differently, or the merge would make dependencies
+overlap, the conflicts are reported and merge-manifest exits with a non-zero
+status, keeping our version of the conflicting dependencies.
+
+To use it as the git merge driver for manifests, run
+
+	git config merge.gvt.name "gvt manifest merge driver"
+	git config merge.gvt.driver "gvt merge-manifest %O %A %B"
+
+and add to .gitattributes the line
+
+	vendor/manifest merge=gvt
+
+`,
+	Run: func(args []string) error {
+		if len(args) != 3 {
+			return fmt.Errorf("merge-manifest: base, ours and theirs manifests are required")
+		}
+
+		var ms [3]*vendor.Manifest
+		for i, path := range args {
+			if fi, err := os.Stat(path); i == 0 && (os.IsNotExist(err) || err == nil && fi.Size() == 0) {
+				// when both sides added the manifest, git passes an empty base
+				ms[i] = new(vendor.Manifest)
+				continue
+			}
+			m, err := vendor.ReadManifest(path)
+			if err != nil {
+				return fmt.Errorf("could not load manifest %s: %v", path, err)
+			}
+			ms[i] = m
+		}
+
+		res, conflicts := vendor.MergeManifests(ms[0], ms[1], ms[2])
+		// git expects the result in ours even if it has no dependencies,
+		// or it would record the manifest as deleted
+		if err := vendor.ReplaceManifest(args[1], res); err != nil {
+			return err
+		}
+
+		for _, c := range conflicts {
+			log.Printf("CONFLICT %s: %s", c.Importpath, c.Reason)
+			log.Printf("\tbase:   %s", describeMergeSide(c.Base))
+			log.Printf("\tours:   %s", describeMergeSide(c.Ours))
+			log.Printf("\ttheirs: %s", describeMergeSide(c.Theirs))
+		}
+		if len(conflicts) > 0 {
+			return fmt.Errorf("%d conflicting dependencies", len(conflicts))
+		}
+		return nil
+	},
+}
+
+func describeMergeSide(d *vendor.Dependency) string {
+	if d == nil {
+		return "(not present)"
+	}
+	return d.Repository + " at " + describeRevision(*d)
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/FiloSottile/gvt/fileutils"
+	"github.com/FiloSottile/gvt/gbvendor"
+)
+
+func TestMergeManifestBothAdded(t *testing.T) {
+	root, err := ioutil.TempDir("", "gvt-merge-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fileutils.RemoveAll(root)
+
+	base := filepath.Join(root, "base")
+	ours := filepath.Join(root, "ours")
+	theirs := filepath.Join(root, "theirs")
+	if err := ioutil.WriteFile(base, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	write := func(path, importpath string) {
+		m := &vendor.Manifest{Dependencies: []vendor.Dependency{{
+			Importpath: importpath,
+			Repository: "https://" + importpath,
+			VCS:        "git",
+			Revision:   "0123456789abcdef",
+			Branch:     "master",
+		}}}
+		if err := vendor.WriteManifest(path, m); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(ours, "github.com/foo/a")
+	write(theirs, "github.com/foo/b")
+
+	if err := cmdMergeManifest.Run([]string{base, ours, theirs}); err != nil {
+		t.Fatalf("merge-manifest with an empty base: %v", err)
+	}
+	m, err := vendor.ReadManifest(ours)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m.Dependencies) != 2 || m.Dependencies[0].Importpath != "github.com/foo/a" ||
+		m.Dependencies[1].Importpath != "github.com/foo/b" {
+		t.Fatalf("expected both added dependencies, got %+v", m.Dependencies)
+	}
+}
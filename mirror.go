@@ -0,0 +1,30 @@
+package main
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/FiloSottile/gvt/gbvendor"
+)
+
+// rewriteRulesFiles returns the paths of the project and user configuration
+// files that hold the rewrite rules, in order of precedence.
+func rewriteRulesFiles() []string {
+	files := []string{filepath.Join(filepath.Dir(vendorDir), ".gvt.conf")}
+	if dir, err := os.UserConfigDir(); err == nil {
+		files = append(files, filepath.Join(dir, "gvt", "config"))
+	}
+	return files
+}
+
+// dependencyRepo returns the RemoteRepo of dep. If its repository can't be
+// reached, it falls back to the mirror recorded in the manifest, if any.
+func dependencyRepo(dep vendor.Dependency, insecure bool) (vendor.RemoteRepo, error) {
+	repo, err := vendor.NewRemoteRepo(cmdCtx, dep.Repository, dep.VCS, insecure)
+	if err != nil && dep.Mirror != "" {
+		log.Printf("%s: %v, trying the recorded mirror %s", dep.Importpath, err, dep.Mirror)
+		repo, err = vendor.NewMirrorRepo(cmdCtx, dep.Repository, dep.Mirror, dep.VCS, insecure)
+	}
+	return repo, err
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/FiloSottile/gvt/gbvendor"
+)
+
+var (
+	pinReasonText string // reason recorded by gvt pin
+)
+
+func addPinFlags(fs *flag.FlagSet) {
+	fs.StringVar(&pinReasonText, "reason", "", "why the dependency is pinned")
+}
+
+var cmdPin = &Command{
+	Name:      "pin",
+	UsageLine: "pin [-reason text] importpath",
+	Short:     "prevent a dependency from being updated",
+	Long: `pin marks a dependency in the manifest so that update leaves it alone.
+
+update -all skips pinned dependencies and lists them when it's done, and
+update refuses to update a pinned dependency by name. Use unpin to remove
+the mark.
+
+Flags:
+	-reason text
+		record why the dependency is pinned. It's shown by list and update.
+
+`,
+	Run: func(args []string) error {
+		if len(args) != 1 {
+			return fmt.Errorf("pin: import path is missing")
+		}
+		return setPinned(args[0], true, pinReasonText)
+	},
+	AddFlags:   addPinFlags,
+	LockVendor: true,
+}
+
+var cmdUnpin = &Command{
+	Name:      "unpin",
+	UsageLine: "unpin importpath",
+	Short:     "allow a pinned dependency to be updated",
+	Long: `unpin removes the mark set by pin, so that update handles the dependency again.
+
+`,
+	Run: func(args []string) error {
+		if len(args) != 1 {
+			return fmt.Errorf("unpin: import path is missing")
+		}
+		return setPinned(args[0], false, "")
+	},
+	LockVendor: true,
+}
+
+// setPinned updates the pin state of the dependency with the given
+// import path and writes back the manifest.
+func setPinned(path string, pinned bool, reason string) error {
+	m, err := vendor.ReadManifest(manifestFile)
+	if err != nil {
+		return fmt.Errorf("could not load manifest: %v", err)
+	}
+
+	dependency, err := m.GetDependencyForImportpath(path)
+	if err != nil {
+		return fmt.Errorf("could not get dependency: %v", err)
+	}
+	if path != dependency.Importpath {
+		return fmt.Errorf("a parent of the specified dependency is vendored, use that instead: %v",
+			dependency.Importpath)
+	}
+
+	for i := range m.Dependencies {
+		if m.Dependencies[i].Importpath == path {
+			m.Dependencies[i].Pinned = pinned
+			m.Dependencies[i].PinReason = reason
+		}
+	}
+
+	return vendor.WriteManifest(manifestFile, m)
+}
+
+// pinReason formats the reason a dependency is pinned, if any,
+// for appending to a message.
+func pinReason(d vendor.Dependency) string {
+	if d.PinReason == "" {
+		return ""
+	}
+	return fmt.Sprintf(" (%s)", d.PinReason)
+}
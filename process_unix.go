@@ -0,0 +1,12 @@
+//go:build !windows
+// +build !windows
+
+package main
+
+import "syscall"
+
+// processAlive reports whether a process with the given pid is running.
+func processAlive(pid int) bool {
+	err := syscall.Kill(pid, 0)
+	return err == nil || err == syscall.EPERM
+}
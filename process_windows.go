@@ -0,0 +1,22 @@
+package main
+
+import "syscall"
+
+const stillActive = 259 // the exit code of running processes
+
+// processAlive reports whether a process with the given pid is running.
+func processAlive(pid int) bool {
+	h, err := syscall.OpenProcess(syscall.PROCESS_QUERY_INFORMATION, false, uint32(pid))
+	if err == syscall.ERROR_ACCESS_DENIED {
+		return true
+	}
+	if err != nil {
+		return false
+	}
+	defer syscall.CloseHandle(h)
+	var code uint32
+	if err := syscall.GetExitCodeProcess(h, &code); err != nil {
+		return true
+	}
+	return code == stillActive
+}
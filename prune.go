@@ -0,0 +1,217 @@
+package main
+
+import (
+	"fmt"
+	"go/build"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/FiloSottile/gvt/fileutils"
+	"github.com/FiloSottile/gvt/gbvendor"
+)
+
+// depSources maps the import paths of the dependencies fetched or updated
+// during this session, and of the pruned ones that had to be checked out
+// again, to their working copies.
+var depSources = make(map[string]vendor.WorkingCopy)
+
+// hasPruned reports whether any dependency of m is pruned.
+func hasPruned(m *vendor.Manifest) bool {
+	for _, d := range m.Dependencies {
+		if d.Prune {
+			return true
+		}
+	}
+	return false
+}
+
+// usedPackages computes the transitive closure of the imports of the
+// project packages and of the import paths of the pruned dependencies.
+//
+// It returns the packages of each dependency in the closure, relative to
+// its import path, and the imports of the pruned packages read from
+// depSources that are neither vendored, in the standard library or in the
+// project.
+func usedPackages(m *vendor.Manifest, insecure bool) (map[string][]string, []string, error) {
+	projectDir := filepath.Dir(vendorDir)
+
+	var queue []string
+	err := filepath.Walk(projectDir, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			return nil
+		}
+		if p == vendorDir || p != projectDir && fileutils.ShouldSkip(p, info, false, false) {
+			return filepath.SkipDir
+		}
+		imports, err := vendor.ParsePackageImports(p, projectDir, importPath, true, false, buildFilter())
+		if err != nil {
+			return err
+		}
+		for i := range imports {
+			queue = append(queue, unvendor(i))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, d := range m.Dependencies {
+		if d.Prune {
+			queue = append(queue, d.Importpath)
+		}
+	}
+
+	used := make(map[string][]string)
+	missing := make(map[string]bool)
+	seen := make(map[string]bool)
+	for len(queue) > 0 {
+		p := queue[0]
+		queue = queue[1:]
+		if seen[p] {
+			continue
+		}
+		seen[p] = true
+
+		dep, err := m.GetDependencyForImportpath(p)
+		if err != nil {
+			continue // not vendored
+		}
+		rel := "."
+		if p != dep.Importpath {
+			rel = p[len(dep.Importpath)+1:]
+		}
+		imports, fromSource, err := packageImports(dep, rel, insecure)
+		if os.IsNotExist(err) {
+			continue // not a package
+		} else if err != nil {
+			return nil, nil, err
+		}
+		used[dep.Importpath] = append(used[dep.Importpath], rel)
+
+		for i := range imports {
+			i = unvendor(i)
+			queue = append(queue, i)
+			if !dep.Prune || !fromSource {
+				continue
+			}
+			switch {
+			case vendor.IsStdlibImport(build.Default.GOROOT, i), !vendor.IsRemoteImportPath(i):
+			case importPath != "" && contains(importPath, i), m.HasImportpath(i):
+			default:
+				missing[i] = true
+			}
+		}
+	}
+
+	for _, pkgs := range used {
+		sort.Strings(pkgs)
+	}
+	var missingList []string
+	for i := range missing {
+		missingList = append(missingList, i)
+	}
+	sort.Strings(missingList)
+	return used, missingList, nil
+}
+
+// packageImports returns the imports of the package rel of dep. It's read
+// from the vendor folder, unless dep was fetched during this session or it's
+// pruned and rel is not one of its recorded packages, in which case it's
+// read from the working copy and fromSource is true.
+func packageImports(dep vendor.Dependency, rel string, insecure bool) (imports map[string]bool, fromSource bool, err error) {
+	filter := buildFilter()
+	wc, ok := depSources[dep.Importpath]
+	if !ok && (!dep.Prune || containsString(dep.Packages, rel)) {
+		dir := filepath.Join(vendorDir, filepath.FromSlash(dep.Importpath), filepath.FromSlash(rel))
+		imports, err := vendor.ParsePackageImports(dir, filepath.Dir(vendorDir), importPath, !dep.NoTests, dep.AllFiles, filter)
+		return imports, false, err
+	}
+	if !ok {
+		// a package pruned from the vendor folder is now imported
+		wc, err = dependencySource(dep, insecure)
+		if err != nil {
+			return nil, false, err
+		}
+	}
+
+	if !strings.HasSuffix(dep.Importpath, dep.Path) {
+		return nil, false, fmt.Errorf("unable to derive the root repo import path")
+	}
+	rootRepoPath := strings.TrimRight(strings.TrimSuffix(dep.Importpath, dep.Path), "/")
+	dir := filepath.Join(wc.Dir(), dep.Path, filepath.FromSlash(rel))
+	imports, err = vendor.ParsePackageImports(dir, wc.Dir(), rootRepoPath, !dep.NoTests, dep.AllFiles, filter)
+	if err != nil || !dep.Flatten {
+		return imports, true, err
+	}
+	flat := make(map[string]bool)
+	for i := range imports {
+		flat[hoistImport(rootRepoPath, i)] = true
+	}
+	return flat, true, nil
+}
+
+// dependencySource returns the working copy of dep at its revision,
+// and adds it to depSources.
+func dependencySource(dep vendor.Dependency, insecure bool) (vendor.WorkingCopy, error) {
+	if wc, ok := depSources[dep.Importpath]; ok {
+		return wc, nil
+	}
+	repo, err := dependencyRepo(dep, insecure)
+	if err != nil {
+		return nil, err
+	}
+	wc, err := download(repo, "", "", dep.Revision)
+	if err != nil {
+		return nil, err
+	}
+	depSources[dep.Importpath] = wc
+	return wc, nil
+}
+
+// writePruned records the used packages of the pruned dependencies of m
+// that are in depSources, and copies them to the vendor folder.
+func writePruned(m *vendor.Manifest, used map[string][]string) error {
+	for i, dep := range m.Dependencies {
+		wc, ok := depSources[dep.Importpath]
+		if !dep.Prune || !ok {
+			continue
+		}
+		dep.Packages = used[dep.Importpath]
+
+		if !dryRun {
+			dst := filepath.Join(vendorDir, filepath.FromSlash(dep.Importpath))
+			if err := fileutils.RemoveAll(dst); err != nil && !os.IsNotExist(err) {
+				return fmt.Errorf("dependency could not be deleted: %v", err)
+			}
+			if err := copyDependency(dst, wc, &dep); err != nil {
+				return err
+			}
+		}
+		m.Dependencies[i] = dep
+	}
+	return nil
+}
+
+// unvendor returns the import path of p, an import of the project or of
+// a dependency in the vendor folder, as resolved by ParseImports.
+func unvendor(p string) string {
+	prefix := importPath + "/vendor/"
+	if strings.HasPrefix(p, prefix) {
+		return p[len(prefix):]
+	}
+	return p
+}
+
+func containsString(list []string, s string) bool {
+	for _, l := range list {
+		if l == s {
+			return true
+		}
+	}
+	return false
+}
@@ -16,16 +16,21 @@ import (
 var (
 	rbInsecure    bool // Allow the use of insecure protocols
 	rbConnections uint // Count of concurrent download connections
+	rbPrune       bool // prune all the dependencies
+	rbFlatten     bool // flatten all the dependencies
 )
 
 func addRestoreFlags(fs *flag.FlagSet) {
 	fs.BoolVar(&rbInsecure, "precaire", false, "allow the use of insecure protocols")
 	fs.UintVar(&rbConnections, "connections", 8, "count of parallel download connections")
+	fs.BoolVar(&rbPrune, "prune", false, "prune all the dependencies")
+	fs.BoolVar(&rbFlatten, "flatten", false, "flatten all the dependencies")
+	addDryRunFlags(fs)
 }
 
 var cmdRestore = &Command{
 	Name:      "restore",
-	UsageLine: "restore [-precaire] [-connections N]",
+	UsageLine: "restore [-precaire] [-connections N] [-prune] [-flatten] [-n [-json]]",
 	Short:     "restore dependencies from manifest",
 	Long: `restore fetches the dependencies listed in the manifest.
 
@@ -35,6 +40,17 @@ source, for example if .gitignore includes lines like
     vendor/**
     !vendor/manifest
 
+Rewrite rules are applied as described in "gvt help fetch". If a repository can't
+be reached, the mirror it was fetched from, if recorded in the manifest, is used.
+
+If a restored dependency has a manifest in its vendor folder, the dependencies
+listed there are restored in that folder too. Dependencies fetched with -flatten
+are instead restored without their vendor folder, and the dependencies listed in
+their manifest are added to the top-level one if missing.
+
+Dependencies fetched with -prune are restored with the packages recorded in the
+manifest.
+
 Note that such a setup requires "gvt restore" to build the source, relies on
 the availability of the dependencies repositories and breaks "go get".
 
@@ -43,16 +59,38 @@ Flags:
 		allow the use of insecure protocols.
 	-connections
 		count of parallel download connections.
+	-prune
+		after restoring, prune all the dependencies like gvt fetch -prune,
+		recomputing the packages imported by the project, and record it
+		in the manifest.
+	-flatten
+		restore all the dependencies like gvt fetch -flatten, as described
+		above, and record it in the manifest. Dependencies listed in nested
+		manifests at a different revision than the top-level one are reported
+		as conflicts.
+	-n
+		check that the repositories can be reached, and print the dependencies
+		that would be fetched, as added if missing from the vendor folder or
+		changed if their files were modified. Nothing is modified.
+	-json
+		with -n, print the changes as a JSON array, like gvt diff -json.
 `,
 	Run: func(args []string) error {
 		switch len(args) {
 		case 0:
+			if dryRun && (rbPrune || rbFlatten) {
+				return fmt.Errorf("-prune and -flatten can't be used with -n")
+			}
+			if dryRun {
+				return restorePlan(manifestFile)
+			}
 			return restore(manifestFile)
 		default:
 			return fmt.Errorf("restore takes no arguments")
 		}
 	},
-	AddFlags: addRestoreFlags,
+	AddFlags:   addRestoreFlags,
+	LockVendor: true,
 }
 
 func restore(manFile string) error {
@@ -61,9 +99,22 @@ func restore(manFile string) error {
 		return fmt.Errorf("could not load manifest: %v", err)
 	}
 
+	unrecorded := 0
+	for i, dep := range m.Dependencies {
+		if dep.Checksum == "" {
+			unrecorded++
+		}
+		if rbFlatten && !dep.Flatten {
+			// the nested vendor folder will be stripped, changing the checksum
+			m.Dependencies[i].Flatten = true
+			m.Dependencies[i].Checksum = ""
+		}
+	}
+	restored := len(m.Dependencies)
+
 	var errors uint32
 	var wg sync.WaitGroup
-	depC := make(chan vendor.Dependency)
+	depC := make(chan *vendor.Dependency)
 	for i := 0; i < int(rbConnections); i++ {
 		wg.Add(1)
 		go func() {
@@ -77,12 +128,37 @@ func restore(manFile string) error {
 		}()
 	}
 
-	for _, dep := range m.Dependencies {
-		depC <- dep
+	for i := range m.Dependencies {
+		depC <- &m.Dependencies[i]
 	}
 	close(depC)
 	wg.Wait()
 
+	errors += hoistNested(m)
+
+	if rbPrune && errors == 0 {
+		for i := range m.Dependencies {
+			m.Dependencies[i].Prune = true
+		}
+		used, missing, err := usedPackages(m, rbInsecure)
+		if err != nil {
+			return fmt.Errorf("failed to compute the imported packages: %v", err)
+		}
+		for _, p := range missing {
+			log.Printf("WARNING: %s is imported but not vendored", p)
+		}
+		if err := writePruned(m, used); err != nil {
+			return err
+		}
+	}
+
+	if unrecorded > 0 || rbPrune || rbFlatten || len(m.Dependencies) > restored {
+		// record the checksums missing from manifests written by older versions
+		if err := vendor.WriteManifest(manFile, m); err != nil {
+			return err
+		}
+	}
+
 	if errors > 0 {
 		return fmt.Errorf("failed to fetch %d dependencies", errors)
 	}
@@ -90,7 +166,7 @@ func restore(manFile string) error {
 	return nil
 }
 
-func downloadDependency(dep vendor.Dependency, errors *uint32, vendorDir string, recursive bool) error {
+func downloadDependency(dep *vendor.Dependency, errors *uint32, vendorDir string, recursive bool) error {
 	extraMsg := ""
 	if !dep.NoTests {
 		extraMsg = "(including tests)"
@@ -104,7 +180,7 @@ func downloadDependency(dep vendor.Dependency, errors *uint32, vendorDir string,
 		log.Printf("fetching %s %s", dep.Importpath, extraMsg)
 	}
 
-	repo, err := vendor.NewRemoteRepo(dep.Repository, dep.VCS, rbInsecure)
+	repo, err := dependencyRepo(*dep, rbInsecure)
 	if err != nil {
 		return fmt.Errorf("dependency could not be processed: %s", err)
 	}
@@ -115,7 +191,6 @@ func downloadDependency(dep vendor.Dependency, errors *uint32, vendorDir string,
 		return fmt.Errorf("dependency could not be fetched: %s", err)
 	}
 	dst := filepath.Join(vendorDir, dep.Importpath)
-	src := filepath.Join(wc.Dir(), dep.Path)
 
 	if _, err := os.Stat(dst); err == nil {
 		if err := fileutils.RemoveAll(dst); err != nil {
@@ -123,15 +198,27 @@ func downloadDependency(dep vendor.Dependency, errors *uint32, vendorDir string,
 		}
 	}
 
-	if err := fileutils.Copypath(dst, src, !dep.NoTests, dep.AllFiles); err != nil {
+	want := dep.Checksum
+	if err := copyDependency(dst, wc, dep); err != nil {
 		return err
 	}
-
-	if err := fileutils.CopyLicense(dst, wc.Dir()); err != nil {
-		return err
+	if want != "" && dep.Checksum != want {
+		got := dep.Checksum
+		dep.Checksum = want
+		return fmt.Errorf("checksum mismatch: manifest has %s, restored files have %s", want, got)
 	}
 
 	// Check for for manifests in dependencies
+	if dep.Flatten {
+		nested, err := readNestedManifest(wc, dep)
+		if err != nil {
+			return err
+		}
+		hoistedMu.Lock()
+		hoisted = append(hoisted, nested...)
+		hoistedMu.Unlock()
+		return nil
+	}
 	man := filepath.Join(dst, "vendor", "manifest")
 	venDir := filepath.Join(dst, "vendor")
 	if _, err := os.Stat(man); err == nil {
@@ -139,7 +226,8 @@ func downloadDependency(dep vendor.Dependency, errors *uint32, vendorDir string,
 		if err != nil {
 			return fmt.Errorf("could not load manifest: %v", err)
 		}
-		for _, d := range m.Dependencies {
+		for i := range m.Dependencies {
+			d := &m.Dependencies[i]
 			if err := downloadDependency(d, errors, venDir, true); err != nil {
 				log.Printf("%s: %v", d.Importpath, err)
 				atomic.AddUint32(errors, 1)
@@ -3,7 +3,11 @@ package main
 import (
 	"flag"
 	"fmt"
+	"go/build"
+	"log"
 	"path/filepath"
+	"sort"
+	"strings"
 
 	"github.com/FiloSottile/gvt/fileutils"
 	"github.com/FiloSottile/gvt/gbvendor"
@@ -16,11 +20,14 @@ var (
 func addUpdateFlags(fs *flag.FlagSet) {
 	fs.BoolVar(&updateAll, "all", false, "update all dependencies")
 	fs.BoolVar(&insecure, "precaire", false, "allow the use of insecure protocols")
+	fs.BoolVar(&prune, "prune", false, "update only the imported packages")
+	addBuildFilterFlags(fs)
+	addDryRunFlags(fs)
 }
 
 var cmdUpdate = &Command{
 	Name:      "update",
-	UsageLine: "update [ -all | importpath ]",
+	UsageLine: "update [-prune] [-os list] [-arch list] [-tags list] [-n [-json]] [ -all | importpath ]",
 	Short:     "update a local dependency",
 	Long: `update replaces the source with the latest available from the head of the fetched branch.
 
@@ -28,6 +35,15 @@ Updating from one copy of a dependency to another is ONLY possible when the
 dependency was fetched by branch, without using -tag or -revision. It will be
 updated to the HEAD of that branch, switching branches is not supported.
 
+Dependencies fetched with -version are instead updated to the highest tag
+matching the recorded constraint.
+
+update warns about the imports of the new revisions that are not vendored.
+They can be added with gvt fetch. It also warns about the packages that declare
+a canonical import path, with an import comment, other than the vendored one.
+
+Pinned dependencies are skipped by -all, and can't be updated until unpinned.
+
 To update across branches, or from one tag/revision to another, you must first
 use delete to remove the dependency, then fetch [ -tag | -revision | -branch ]
 to replace it.
@@ -37,7 +53,10 @@ Flags:
 		update all dependencies in the manifest.
 	-precaire
 		allow the use of insecure protocols.
-
+	-prune
+		vendor only the packages imported by the project, like gvt fetch -prune.
+		Dependencies fetched with -prune are always pruned.
+` + buildFilterFlagsDoc + dryRunFlagsDoc + `
 `,
 	Run: func(args []string) error {
 		if len(args) != 1 && !updateAll {
@@ -51,54 +70,102 @@ Flags:
 			return fmt.Errorf("could not load manifest: %v", err)
 		}
 
-		var dependencies []vendor.Dependency
+		old := copyManifest(m)
+		var dependencies, pinned []vendor.Dependency
 		if updateAll {
-			dependencies = make([]vendor.Dependency, len(m.Dependencies))
-			copy(dependencies, m.Dependencies)
+			for _, d := range m.Dependencies {
+				if d.Pinned {
+					pinned = append(pinned, d)
+				} else {
+					dependencies = append(dependencies, d)
+				}
+			}
 		} else {
 			p := args[0]
 			dependency, err := m.GetDependencyForImportpath(p)
 			if err != nil {
 				return fmt.Errorf("could not get dependency: %v", err)
 			}
+			if dependency.Pinned {
+				return fmt.Errorf("%s is pinned%s, use gvt unpin first", dependency.Importpath, pinReason(dependency))
+			}
 			dependencies = append(dependencies, dependency)
 		}
 
+		prunedUpdates := make(map[string]vendor.Dependency)
 		for _, d := range dependencies {
 			err = m.RemoveDependency(d)
 			if err != nil {
 				return fmt.Errorf("dependency could not be deleted from manifest: %v", err)
 			}
 
-			repo, err := vendor.NewRemoteRepo(d.Repository, d.VCS, insecure)
+			repo, err := dependencyRepo(d, insecure)
 			if err != nil {
 				return fmt.Errorf("could not determine repository for import %q", d.Importpath)
 			}
 
-			wc, err := GlobalDownloader.Get(repo, d.Branch, "", "")
+			var wc vendor.WorkingCopy
+			if d.Constraint != "" {
+				var tag string
+				tag, err = latestTag(repo, d.Constraint)
+				if err != nil {
+					return err
+				}
+				wc, err = GlobalDownloader.Get(repo, "", tag, "")
+			} else {
+				wc, err = GlobalDownloader.Get(repo, d.Branch, "", "")
+			}
+			if err != nil {
+				return err
+			}
+
+			rev, err := wc.Revision(cmdCtx)
 			if err != nil {
 				return err
 			}
 
-			rev, err := wc.Revision()
+			branch, err := wc.Branch(cmdCtx)
 			if err != nil {
 				return err
 			}
 
-			branch, err := wc.Branch()
+			dep := d
+			dep.Repository = repo.URL()
+			dep.Mirror = vendor.MirrorURL(repo)
+			dep.VCS = repo.Type()
+			dep.Revision = rev
+			dep.Branch = branch
+			dep.Prune = dep.Prune || prune
+
+			missing, mismatches, err := unvendoredImports(m, dep, wc)
 			if err != nil {
 				return err
 			}
+			for _, mm := range mismatches {
+				log.Printf("WARNING: %s has canonical import path %s, consider fetching %s instead",
+					mm.path, mm.canonical, mm.root(dep.Importpath))
+			}
+
+			// pruned dependencies are copied once all are updated,
+			// until then the manifest keeps the current revision
+			depSources[dep.Importpath] = wc
+			if dep.Prune {
+				prunedUpdates[dep.Importpath] = dep
+				if err := m.AddDependency(d); err != nil {
+					return err
+				}
+				continue
+			}
 
-			dep := vendor.Dependency{
-				Importpath: d.Importpath,
-				Repository: repo.URL(),
-				VCS:        repo.Type(),
-				Revision:   rev,
-				Branch:     branch,
-				Path:       d.Path,
-				NoTests:    d.NoTests,
-				AllFiles:   d.AllFiles,
+			for _, p := range missing {
+				log.Printf("WARNING: %s imports %s, which is not vendored", dep.Importpath, p)
+			}
+
+			if dryRun {
+				if err := m.AddDependency(dep); err != nil {
+					return err
+				}
+				continue
 			}
 
 			if err := fileutils.RemoveAll(filepath.Join(vendorDir, filepath.FromSlash(d.Importpath))); err != nil {
@@ -107,26 +174,96 @@ Flags:
 			}
 
 			dst := filepath.Join(vendorDir, filepath.FromSlash(dep.Importpath))
-			src := filepath.Join(wc.Dir(), dep.Path)
 
-			if err := fileutils.Copypath(dst, src, !d.NoTests, d.AllFiles); err != nil {
+			if err := copyDependency(dst, wc, &dep); err != nil {
 				return err
 			}
 
-			if err := fileutils.CopyLicense(dst, wc.Dir()); err != nil {
+			if err := m.AddDependency(dep); err != nil {
 				return err
 			}
 
-			if err := m.AddDependency(dep); err != nil {
+			if err := vendor.WriteManifest(manifestFile, m); err != nil {
 				return err
 			}
+		}
 
-			if err := vendor.WriteManifest(manifestFile, m); err != nil {
+		for i, d := range m.Dependencies {
+			if dep, ok := prunedUpdates[d.Importpath]; ok {
+				m.Dependencies[i] = dep
+			}
+		}
+		if hasPruned(m) {
+			used, missing, err := usedPackages(m, insecure)
+			if err != nil {
+				return fmt.Errorf("failed to compute the imported packages: %v", err)
+			}
+			for _, p := range missing {
+				log.Printf("WARNING: %s is imported but not vendored", p)
+			}
+			if err := writePruned(m, used); err != nil {
 				return err
 			}
+			if !dryRun {
+				if err := vendor.WriteManifest(manifestFile, m); err != nil {
+					return err
+				}
+			}
 		}
 
+		if len(pinned) > 0 {
+			log.Printf("Skipped %d pinned dependencies:", len(pinned))
+			for _, d := range pinned {
+				log.Printf("\t%s%s", d.Importpath, pinReason(d))
+			}
+		}
+
+		if dryRun {
+			return printPlan(old, m)
+		}
 		return nil
 	},
-	AddFlags: addUpdateFlags,
+	AddFlags:   addUpdateFlags,
+	LockVendor: true,
+}
+
+// unvendoredImports returns the imports of dep, as checked out in wc,
+// that are not in the standard library, dep itself or the manifest m.
+// If dep is flattened, its nested vendored imports are checked for
+// conflicts, and reported if missing from m. It also returns the packages
+// of dep whose canonical import paths don't match the vendored ones.
+func unvendoredImports(m *vendor.Manifest, dep vendor.Dependency, wc vendor.WorkingCopy) ([]string, []importMismatch, error) {
+	if !strings.HasSuffix(dep.Importpath, dep.Path) {
+		return nil, nil, fmt.Errorf("unable to derive the root repo import path")
+	}
+	rootRepoPath := strings.TrimRight(strings.TrimSuffix(dep.Importpath, dep.Path), "/")
+	src := filepath.Join(wc.Dir(), dep.Path)
+	imports, comments, err := vendor.ParseImportsAndComments(src, wc.Dir(), rootRepoPath, !dep.NoTests, dep.AllFiles, buildFilter())
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to parse imports: %s", err)
+	}
+
+	var nested []vendor.LockedDependency
+	if dep.Flatten {
+		if nested, _, err = vendor.ReadLockFile(wc.Dir()); err != nil {
+			log.Printf("Ignoring lock file of %s: %v", dep.Importpath, err)
+		}
+	}
+
+	var missing []string
+	for p := range imports {
+		if dep.Flatten {
+			p = hoistImport(rootRepoPath, p)
+			warnConflict(m, 0, dep.Importpath, nested, p)
+		}
+		switch {
+		case vendor.IsStdlibImport(build.Default.GOROOT, p):
+		case contains(rootRepoPath, p), m.HasImportpath(p):
+		case importPath != "" && contains(importPath, p):
+		default:
+			missing = append(missing, p)
+		}
+	}
+	sort.Strings(missing)
+	return missing, canonicalMismatches(dep.Importpath, comments), nil
 }
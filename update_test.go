@@ -0,0 +1,75 @@
+package main
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/FiloSottile/gvt/fileutils"
+	"github.com/FiloSottile/gvt/gbvendor"
+)
+
+func TestUpdateConstraintCheckoutFails(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not found")
+	}
+	root, err := ioutil.TempDir("", "gvt-update-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fileutils.RemoveAll(root)
+
+	upstream := filepath.Join(root, "upstream")
+	if err := os.Mkdir(upstream, 0755); err != nil {
+		t.Fatal(err)
+	}
+	git := func(args ...string) {
+		args = append([]string{"-c", "user.name=gvt", "-c", "user.email=gvt@example.com"}, args...)
+		cmd := exec.Command("git", args...)
+		cmd.Dir = upstream
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	git("init", "-q")
+	git("commit", "-q", "--allow-empty", "-m", "first")
+	git("tag", "v1.2.0")
+
+	defer func(mf, vd string) { manifestFile, vendorDir = mf, vd }(manifestFile, vendorDir)
+	vendorDir = filepath.Join(root, "vendor")
+	manifestFile = filepath.Join(vendorDir, "manifest")
+	if err := os.Mkdir(vendorDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	m := &vendor.Manifest{Dependencies: []vendor.Dependency{{
+		Importpath: "example.com/dep",
+		Repository: upstream,
+		VCS:        "git",
+		Revision:   "0123456789abcdef",
+		Constraint: "^1.0",
+	}}}
+	if err := vendor.WriteManifest(manifestFile, m); err != nil {
+		t.Fatal(err)
+	}
+
+	// make the checkout of the selected tag fail
+	key := cacheKey{url: upstream, repoType: "git", tag: "v1.2.0"}
+	entry := &cacheEntry{err: errors.New("checkout failed")}
+	GlobalDownloader.wcsMu.Lock()
+	GlobalDownloader.wcs[key] = entry
+	GlobalDownloader.wcsMu.Unlock()
+	defer func() {
+		GlobalDownloader.wcsMu.Lock()
+		delete(GlobalDownloader.wcs, key)
+		GlobalDownloader.wcsMu.Unlock()
+	}()
+
+	err = cmdUpdate.Run([]string{"example.com/dep"})
+	if err == nil || !strings.Contains(err.Error(), "checkout failed") {
+		t.Fatalf("expected the checkout error, got %v", err)
+	}
+}
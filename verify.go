@@ -0,0 +1,118 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+
+	"github.com/FiloSottile/gvt/gbvendor"
+)
+
+var cmdVerify = &Command{
+	Name:      "verify",
+	UsageLine: "verify",
+	Short:     "check vendored files against the manifest checksums",
+	Long: `verify recomputes the checksum of each vendored dependency and compares it
+with the one recorded in the manifest by fetch, update and restore.
+
+Each dependency that does not match is reported as one of
+
+	missing     the dependency folder does not exist
+	incomplete  the dependency folder contains no files
+	modified    the vendored files were edited, added or removed
+
+The vendor folder of each dependency is not checked, as restore fills it
+from the manifest of the dependency.
+
+Dependencies without a recorded checksum, from manifests written by older
+versions of gvt, are reported as unverified but do not cause a failure.
+Running gvt restore records their checksums.
+
+verify exits with a non-zero status if any dependency fails verification.
+`,
+	Run: func(args []string) error {
+		if len(args) != 0 {
+			return fmt.Errorf("verify takes no arguments")
+		}
+
+		m, err := vendor.ReadManifest(manifestFile)
+		if err != nil {
+			return fmt.Errorf("could not load manifest: %v", err)
+		}
+
+		var failed int
+		w := tabwriter.NewWriter(os.Stdout, 1, 2, 1, ' ', 0)
+		for _, dep := range m.Dependencies {
+			status, err := verifyDependency(dep)
+			if err != nil {
+				return fmt.Errorf("could not verify %s: %v", dep.Importpath, err)
+			}
+			if status == "" {
+				continue
+			}
+			if status != "unverified" {
+				failed++
+			}
+			fmt.Fprintf(w, "%s\t%s\n", status, dep.Importpath)
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+
+		if failed > 0 {
+			return fmt.Errorf("%d dependencies failed verification", failed)
+		}
+		return nil
+	},
+}
+
+var errFoundFile = errors.New("found a file")
+
+// verifyDependency returns the status of dep as documented in cmdVerify,
+// or the empty string if it matches its checksum.
+func verifyDependency(dep vendor.Dependency) (string, error) {
+	dir := filepath.Join(vendorDir, filepath.FromSlash(dep.Importpath))
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		return "missing", nil
+	} else if err != nil {
+		return "", err
+	}
+
+	if ok, err := hasFiles(dir); err != nil {
+		return "", err
+	} else if !ok {
+		return "incomplete", nil
+	}
+
+	if dep.Checksum == "" {
+		return "unverified", nil
+	}
+	sum, err := vendor.Checksum(dir)
+	if err != nil {
+		return "", err
+	}
+	if sum != dep.Checksum {
+		return "modified", nil
+	}
+	return "", nil
+}
+
+// hasFiles reports whether the tree rooted at dir contains anything but
+// directories.
+func hasFiles(dir string) (bool, error) {
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			return errFoundFile
+		}
+		return nil
+	})
+	if err == errFoundFile {
+		return true, nil
+	}
+	return false, err
+}